@@ -0,0 +1,128 @@
+package main
+
+import (
+	"calculator-service/internal/types"
+	"log/slog"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// queueDepth - готовые задачи в очереди оркестратора по последнему ответу на запрос задачи
+var queueDepth atomic.Int64
+
+// autoscaler подбирает число воркеров между MIN_WORKERS и MAX_WORKERS по очереди оркестратора
+// и загрузке процессора агентом. Без MAX_WORKERS воркеров ровно COMPUTING_POWER
+type autoscaler struct {
+	pool    *workerPool
+	cpuTime func() (time.Duration, bool)
+
+	mu        sync.Mutex
+	target    int
+	resizedAt time.Time
+	cpuUsage  float64
+	lastCPU   time.Duration
+	lastAt    time.Time
+}
+
+// apply применяет настройки сразу, не дожидаясь очередного интервала
+func (a *autoscaler) apply(s *settings) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	target := s.computingPower
+	if s.autoscaling() {
+		target = min(max(a.target, s.minWorkers), s.maxWorkers)
+	}
+	a.setTarget(target)
+}
+
+func (a *autoscaler) run() {
+	for {
+		time.Sleep(getSettings().autoscaleInterval)
+		a.tick(time.Now())
+	}
+}
+
+func (a *autoscaler) tick(now time.Time) {
+	s := getSettings()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.measureCPU(now)
+	if !s.autoscaling() {
+		return
+	}
+	busy := int(workersGauge.Value("busy"))
+	want := desiredWorkers(a.target, busy, int(queueDepth.Load()), a.cpuUsage, s)
+	if want < a.target && now.Sub(a.resizedAt) < s.autoscaleInterval {
+		return
+	}
+	if want != a.target {
+		a.resizedAt = now
+	}
+	a.setTarget(want)
+}
+
+// desiredWorkers - воркеров должно хватать на выполняющиеся и готовые задачи. Рост происходит сразу,
+// а уменьшение - по одному воркеру и не раньше чем через AUTOSCALE_INTERVAL после прошлого изменения
+// размера, чтобы пул не колебался при неровной нагрузке.
+// При загрузке процессора выше AUTOSCALE_CPU_LIMIT пул не растёт и постепенно уменьшается
+func desiredWorkers(current, busy, queued int, cpuUsage float64, s *settings) int {
+	want := busy + queued
+	if cpuUsage > s.cpuLimit || want < current {
+		want = current - 1
+	}
+	return min(max(want, s.minWorkers), s.maxWorkers)
+}
+
+// measureCPU считает долю процессорного времени всех ядер, занятую агентом с прошлого замера. Вызывается под a.mu
+func (a *autoscaler) measureCPU(now time.Time) {
+	cpu, ok := a.cpuTime()
+	if !ok {
+		return
+	}
+	if elapsed := now.Sub(a.lastAt); !a.lastAt.IsZero() && elapsed > 0 {
+		a.cpuUsage = float64(cpu-a.lastCPU) / (float64(elapsed) * float64(runtime.NumCPU()))
+		cpuUsageGauge.Set(a.cpuUsage)
+	}
+	a.lastCPU, a.lastAt = cpu, now
+}
+
+// setTarget меняет размер пула. Вызывается под a.mu
+func (a *autoscaler) setTarget(n int) {
+	if n != a.target {
+		slog.Info("worker pool resized", "from", a.target, "to", n, "queue_depth", queueDepth.Load(), "cpu_usage", a.cpuUsage)
+	}
+	a.target = n
+	workersTarget.Set(float64(n))
+	a.pool.resize(n)
+}
+
+func (a *autoscaler) status() types.AgentStatus {
+	s := getSettings()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	workers := types.WorkersStatus{
+		Autoscaling: s.autoscaling(),
+		Target:      a.target,
+		Min:         s.computingPower,
+		Max:         s.computingPower,
+		Busy:        int(workersGauge.Value("busy")),
+	}
+	if s.autoscaling() {
+		workers.Min, workers.Max = s.minWorkers, s.maxWorkers
+	}
+
+	return types.AgentStatus{
+		ID:           AGENT_ID,
+		Workers:      workers,
+		QueueDepth:   int(queueDepth.Load()),
+		CPUUsage:     a.cpuUsage,
+		Capabilities: s.capabilities,
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func autoscaleSettings(minWorkers, maxWorkers int) *settings {
+	return &settings{
+		computingPower:    3,
+		minWorkers:        minWorkers,
+		maxWorkers:        maxWorkers,
+		cpuLimit:          0.8,
+		autoscaleInterval: 5 * time.Second,
+	}
+}
+
+func TestDesiredWorkers(t *testing.T) {
+	tests := []struct {
+		name    string
+		current int
+		busy    int
+		queued  int
+		cpu     float64
+		want    int
+	}{
+		{"рост по очереди", 2, 2, 3, 0.1, 5},
+		{"рост ограничен MAX_WORKERS", 2, 2, 30, 0.1, 10},
+		{"загрузка без очереди сохраняет размер", 4, 4, 0, 0.1, 4},
+		{"CPU выше лимита уменьшает пул при очереди", 5, 5, 10, 0.95, 4},
+		{"уменьшение на одного без нагрузки", 6, 1, 0, 0.1, 5},
+		{"не ниже MIN_WORKERS", 2, 0, 0, 0.1, 2},
+		{"не ниже MIN_WORKERS при высоком CPU", 2, 2, 5, 0.95, 2},
+		{"размер выше MAX_WORKERS после перечитывания настроек", 15, 15, 0, 0.1, 10},
+	}
+
+	s := autoscaleSettings(2, 10)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := desiredWorkers(tt.current, tt.busy, tt.queued, tt.cpu, s); got != tt.want {
+				t.Errorf("desiredWorkers(%d, %d, %d, %v) = %d, ожидается %d", tt.current, tt.busy, tt.queued, tt.cpu, got, tt.want)
+			}
+		})
+	}
+}
+
+// newTestAutoscaler - автомасштабирование с воркерами, которые не обращаются к оркестратору,
+// и загрузкой процессора cpu с прошлого замера секунду назад
+func newTestAutoscaler(t *testing.T, s *settings, target int, cpu float64, now time.Time) *autoscaler {
+	t.Helper()
+
+	prev := getSettings()
+	currentSettings.Store(s)
+	t.Cleanup(func() { currentSettings.Store(prev) })
+
+	a := &autoscaler{
+		pool: &workerPool{work: func(int) { time.Sleep(time.Millisecond) }},
+		cpuTime: func() (time.Duration, bool) {
+			return time.Duration(cpu * float64(runtime.NumCPU()) * float64(time.Second)), true
+		},
+		lastAt: now.Add(-time.Second),
+	}
+	a.mu.Lock()
+	a.setTarget(target)
+	a.mu.Unlock()
+	t.Cleanup(func() { a.pool.resize(0) })
+	return a
+}
+
+// setLoad задаёт число занятых воркеров и очередь оркестратора до конца теста
+func setLoad(t *testing.T, busy, queued int) {
+	t.Helper()
+
+	workersGauge.Add(float64(busy), "busy")
+	queueDepth.Store(int64(queued))
+	t.Cleanup(func() {
+		workersGauge.Add(-float64(busy), "busy")
+		queueDepth.Store(0)
+	})
+}
+
+func TestAutoscalerTick(t *testing.T) {
+	tests := []struct {
+		name        string
+		settings    *settings
+		target      int
+		sinceResize time.Duration
+		busy        int
+		queued      int
+		cpu         float64
+		want        int
+	}{
+		{"рост по очереди", autoscaleSettings(1, 10), 2, time.Hour, 2, 3, 0.1, 5},
+		{"рост не ждёт интервала", autoscaleSettings(1, 10), 5, time.Second, 5, 3, 0.1, 8},
+		{"рост ограничен MAX_WORKERS", autoscaleSettings(1, 10), 2, time.Hour, 2, 30, 0.1, 10},
+		{"CPU выше лимита", autoscaleSettings(1, 10), 5, time.Hour, 5, 10, 0.95, 4},
+		{"уменьшение без нагрузки", autoscaleSettings(1, 10), 5, time.Hour, 1, 0, 0.1, 4},
+		{"не ниже MIN_WORKERS", autoscaleSettings(2, 10), 2, time.Hour, 0, 0, 0.1, 2},
+		{"уменьшение ждёт интервала после изменения", autoscaleSettings(1, 10), 5, time.Second, 1, 0, 0.1, 5},
+		{"CPU выше лимита ждёт интервала", autoscaleSettings(1, 10), 5, time.Second, 5, 10, 0.95, 5},
+		{"без MAX_WORKERS размер не меняется", autoscaleSettings(1, 0), 3, time.Hour, 3, 10, 0.1, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			a := newTestAutoscaler(t, tt.settings, tt.target, tt.cpu, now)
+			a.resizedAt = now.Add(-tt.sinceResize)
+			setLoad(t, tt.busy, tt.queued)
+
+			a.tick(now)
+
+			if a.target != tt.want {
+				t.Errorf("воркеров = %d, ожидается %d", a.target, tt.want)
+			}
+			if workers := len(a.pool.stops); workers != tt.want {
+				t.Errorf("запущено воркеров = %d, ожидается %d", workers, tt.want)
+			}
+		})
+	}
+}
+
+func TestAutoscalerShrinksOncePerInterval(t *testing.T) {
+	start := time.Now()
+	a := newTestAutoscaler(t, autoscaleSettings(1, 10), 1, 0.1, start)
+	setLoad(t, 0, 6)
+
+	a.tick(start)
+	if a.target != 6 {
+		t.Fatalf("воркеров = %d, ожидается 6 по очереди", a.target)
+	}
+
+	// Очередь разобрана: пул уменьшается по одному воркеру не чаще раза в AUTOSCALE_INTERVAL
+	queueDepth.Store(0)
+	steps := []struct {
+		after time.Duration
+		want  int
+	}{
+		{time.Second, 6},
+		{5 * time.Second, 5},
+		{7 * time.Second, 5},
+		{10 * time.Second, 4},
+		{15 * time.Second, 3},
+	}
+	for _, step := range steps {
+		a.tick(start.Add(step.after))
+		if a.target != step.want {
+			t.Errorf("через %v: воркеров = %d, ожидается %d", step.after, a.target, step.want)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"calculator-service/internal/logging"
+	"calculator-service/internal/types"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// resultFlushDelay - сколько результат может ждать попутчиков перед отправкой. Намного меньше
+// времени операции, чтобы задачи, которым нужен результат, не ждали остальных воркеров
+const resultFlushDelay = 20 * time.Millisecond
+
+// resultRetryDelays - паузы между повторными отправками результатов, которые не дошли до оркестратора.
+// Если результаты так и не доставлены, оркестратор выдаст их задачи снова, когда истечёт аренда
+var resultRetryDelays = []time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second, 4 * time.Second}
+
+var (
+	batcher = newTaskBatcher(func(n int) ([]types.Task, int) {
+		return fetchTasks(context.Background(), n)
+	})
+	results = newResultBatcher(types.MaxTaskBatch, resultFlushDelay, func(batch []types.TaskResult) {
+		sendResults(context.Background(), batch)
+	})
+)
+
+func processBatchTask(workerID int) {
+	ctx := logging.With(context.Background(), "worker_id", workerID)
+
+	task, version, ok := batcher.take(batchSize())
+	if !ok {
+		time.Sleep(time.Second)
+		return
+	}
+
+	ctx = taskContext(ctx, task)
+	result, traceparent := execute(ctx, workerID, task, version, task.Traceparent)
+	result.Traceparent = traceparent
+	results.add(result)
+	slog.DebugContext(ctx, "task completed", "operation", task.Operation, "result", result.Result)
+}
+
+// batchSize - пакет на все свободные воркеры пула
+func batchSize() int {
+	return min(max(int(workersGauge.Value("idle")), 1), types.MaxTaskBatch)
+}
+
+// taskBatcher раздаёт воркерам задачи, полученные одним запросом к оркестратору. Новый пакет
+// запрашивает первый освободившийся воркер, когда задачи прошлого пакета разобраны, на все
+// свободные в этот момент воркеры. Остальные свободные воркеры ждут этот пакет
+type taskBatcher struct {
+	fetch func(n int) ([]types.Task, int)
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    []types.Task
+	version  int
+	fetching bool
+	closed   bool
+}
+
+func newTaskBatcher(fetch func(n int) ([]types.Task, int)) *taskBatcher {
+	b := &taskBatcher{fetch: fetch}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// take возвращает задачу и версию протокола, по которой она получена. Если пакета нет,
+// запрашивает до n задач. false - новых задач у оркестратора нет
+func (b *taskBatcher) take(n int) (types.Task, int, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for len(b.queue) == 0 || b.closed {
+		if b.closed {
+			return types.Task{}, 0, false
+		}
+		if b.fetching {
+			b.cond.Wait()
+			// Пакет получен, но задач для этого воркера в нём не оказалось
+			if !b.fetching && len(b.queue) == 0 {
+				return types.Task{}, 0, false
+			}
+			continue
+		}
+
+		b.fetching = true
+		b.mu.Unlock()
+		tasks, version := b.fetch(n)
+		b.mu.Lock()
+
+		b.fetching = false
+		b.queue, b.version = tasks, version
+		b.cond.Broadcast()
+		if len(tasks) == 0 {
+			return types.Task{}, 0, false
+		}
+	}
+
+	task := b.queue[0]
+	b.queue = b.queue[1:]
+	return task, b.version, true
+}
+
+// close перестаёт раздавать задачи и возвращает число полученных, но не начатых задач.
+// Оркестратор выдаст их снова, когда истечёт аренда
+func (b *taskBatcher) close() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	dropped := len(b.queue)
+	b.queue = nil
+	b.cond.Broadcast()
+	return dropped
+}
+
+// resultBatcher копит результаты и отправляет их одним запросом: когда набралось maxSize
+// результатов или первый из них ждёт maxWait
+type resultBatcher struct {
+	maxSize int
+	maxWait time.Duration
+	send    func([]types.TaskResult)
+
+	mu      sync.Mutex
+	pending []types.TaskResult
+	timer   *time.Timer
+	closed  bool
+	sending sync.WaitGroup
+}
+
+func newResultBatcher(maxSize int, maxWait time.Duration, send func([]types.TaskResult)) *resultBatcher {
+	return &resultBatcher{maxSize: maxSize, maxWait: maxWait, send: send}
+}
+
+func (b *resultBatcher) add(result types.TaskResult) {
+	b.mu.Lock()
+	b.pending = append(b.pending, result)
+	if !b.closed && len(b.pending) < b.maxSize {
+		if b.timer == nil {
+			b.timer = time.AfterFunc(b.maxWait, b.flush)
+		}
+		b.mu.Unlock()
+		return
+	}
+	b.mu.Unlock()
+	b.flush()
+}
+
+// flush отправляет накопленные результаты
+func (b *resultBatcher) flush() {
+	b.mu.Lock()
+	batch := b.pending
+	b.pending = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(batch) == 0 {
+		b.mu.Unlock()
+		return
+	}
+	b.sending.Add(1)
+	b.mu.Unlock()
+
+	defer b.sending.Done()
+	b.send(batch)
+}
+
+// close отправляет оставшиеся результаты и дожидается отправок, начатых раньше. Результаты,
+// пришедшие после close, отправляются сразу
+func (b *resultBatcher) close() {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+
+	b.flush()
+	b.sending.Wait()
+}
+
+// fetchTasks запрашивает до n задач. Если оркестратор перестал поддерживать пакетные запросы,
+// воркеры возвращаются к получению задач по одной
+func fetchTasks(ctx context.Context, n int) ([]types.Task, int) {
+	req, err := newOrchestratorRequest(http.MethodGet, "/internal/tasks?max="+strconv.Itoa(n), nil, types.ProtocolVersion)
+	if err != nil {
+		slog.ErrorContext(ctx, "error building tasks request", "error", err)
+		return nil, 0
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		httpErrorsTotal.Inc("get_tasks", "transport")
+		slog.ErrorContext(ctx, "error getting tasks", "error", err)
+		return nil, 0
+	}
+	defer resp.Body.Close()
+
+	if depth, err := strconv.Atoi(resp.Header.Get(types.QueueDepthHeader)); err == nil {
+		queueDepth.Store(int64(depth))
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return nil, 0
+	case http.StatusNotFound, http.StatusBadRequest:
+		httpErrorsTotal.Inc("get_tasks", "protocol")
+		slog.WarnContext(ctx, "orchestrator does not support batch requests, fetching tasks one by one", "status", resp.StatusCode)
+		orchestratorVersion.Store(0)
+		return nil, 0
+	default:
+		httpErrorsTotal.Inc("get_tasks", "status")
+		slog.ErrorContext(ctx, "unexpected status code", "status", resp.StatusCode)
+		return nil, 0
+	}
+
+	version, err := types.ParseProtocolVersion(resp.Header.Get(types.ProtocolVersionHeader))
+	if err != nil {
+		httpErrorsTotal.Inc("get_tasks", "protocol")
+		slog.ErrorContext(ctx, "invalid protocol version", "error", err)
+		return nil, 0
+	}
+	version, _ = types.NegotiateProtocol(version)
+
+	var batch types.TaskBatch
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		httpErrorsTotal.Inc("get_tasks", "decode")
+		slog.ErrorContext(ctx, "error decoding tasks", "error", err)
+		return nil, 0
+	}
+	slog.DebugContext(ctx, "tasks received", "requested", n, "received", len(batch.Tasks))
+	return batch.Tasks, version
+}
+
+// sendResults отправляет результаты и пишет в лог, какие из них оркестратор не принял.
+// Если оркестратор недоступен или ответил 5xx, отправка повторяется с паузами resultRetryDelays
+func sendResults(ctx context.Context, batch []types.TaskResult) {
+	var (
+		rejected []types.TaskResultStatus
+		err      error
+	)
+	for attempt := 0; ; attempt++ {
+		rejected, err = submitResults(ctx, batch)
+		if err == nil {
+			break
+		}
+		if attempt == len(resultRetryDelays) || !retryable(err) {
+			slog.ErrorContext(ctx, "error sending results", "results", len(batch), "attempts", attempt+1, "error", err)
+			return
+		}
+		slog.WarnContext(ctx, "error sending results, retrying", "results", len(batch), "retry_in", resultRetryDelays[attempt], "error", err)
+		time.Sleep(resultRetryDelays[attempt])
+	}
+
+	for _, status := range rejected {
+		slog.WarnContext(ctx, "task result rejected", "task_id", status.ID, "error_code", status.ErrorCode, "error", status.Error)
+	}
+	slog.InfoContext(ctx, "task results sent", "results", len(batch), "accepted", len(batch)-len(rejected))
+}
+
+// submitResults отправляет результаты одним запросом и возвращает те, которые оркестратор не принял
+func submitResults(ctx context.Context, batch []types.TaskResult) ([]types.TaskResultStatus, error) {
+	body, err := json.Marshal(types.TaskResultBatch{Results: batch})
+	if err != nil {
+		return nil, fmt.Errorf("marshal results: %w", err)
+	}
+
+	req, err := newOrchestratorRequest(http.MethodPost, "/internal/tasks", body, types.ProtocolVersion)
+	if err != nil {
+		return nil, fmt.Errorf("build results request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		httpErrorsTotal.Inc("submit_results", "transport")
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		httpErrorsTotal.Inc("submit_results", "status")
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &statusError{status: resp.StatusCode, detail: string(bytes.TrimSpace(detail))}
+	}
+
+	var response types.TaskResultBatchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		httpErrorsTotal.Inc("submit_results", "decode")
+		return nil, fmt.Errorf("decode results response: %w", err)
+	}
+
+	var rejected []types.TaskResultStatus
+	for _, status := range response.Results {
+		if !status.Accepted {
+			rejected = append(rejected, status)
+		}
+	}
+	return rejected, nil
+}
+
+// statusError - оркестратор ответил на запрос агента ошибкой
+type statusError struct {
+	status int
+	detail string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("orchestrator responded with status %d: %s", e.status, e.detail)
+}
+
+// retryable - запрос стоит повторить: оркестратор недоступен, перегружен или ответил 5xx.
+// Ошибки в самом запросе при повторе не исчезнут
+func retryable(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.status >= http.StatusInternalServerError || statusErr.status == http.StatusTooManyRequests
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"calculator-service/internal/api"
+	"calculator-service/internal/types"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sentBatches собирает пакеты, которые resultBatcher отправил бы оркестратору
+type sentBatches struct {
+	mu      sync.Mutex
+	batches [][]types.TaskResult
+	sent    chan struct{}
+}
+
+func newSentBatches() *sentBatches {
+	return &sentBatches{sent: make(chan struct{}, 100)}
+}
+
+func (s *sentBatches) send(batch []types.TaskResult) {
+	s.mu.Lock()
+	s.batches = append(s.batches, batch)
+	s.mu.Unlock()
+	s.sent <- struct{}{}
+}
+
+func (s *sentBatches) sizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sizes []int
+	for _, batch := range s.batches {
+		sizes = append(sizes, len(batch))
+	}
+	return sizes
+}
+
+func result(i int) types.TaskResult {
+	return types.TaskResult{ID: "task-" + strconv.Itoa(i), Result: float64(i)}
+}
+
+func TestResultBatcherFlushOnSize(t *testing.T) {
+	sent := newSentBatches()
+	b := newResultBatcher(3, time.Hour, sent.send)
+
+	for i := 0; i < 7; i++ {
+		b.add(result(i))
+	}
+	if got := sent.sizes(); !reflect.DeepEqual(got, []int{3, 3}) {
+		t.Fatalf("отправлены пакеты %v, ожидается [3 3] без ожидания таймера", got)
+	}
+
+	b.close()
+	if got := sent.sizes(); !reflect.DeepEqual(got, []int{3, 3, 1}) {
+		t.Errorf("после close отправлены пакеты %v, ожидается [3 3 1]", got)
+	}
+}
+
+func TestResultBatcherFlushOnTimeout(t *testing.T) {
+	sent := newSentBatches()
+	b := newResultBatcher(100, 10*time.Millisecond, sent.send)
+
+	start := time.Now()
+	b.add(result(1))
+	b.add(result(2))
+
+	select {
+	case <-sent.sent:
+	case <-time.After(time.Second):
+		t.Fatal("результаты не отправлены по таймеру")
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("результаты отправлены через %v, ожидается не раньше maxWait", elapsed)
+	}
+	if got := sent.sizes(); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("отправлены пакеты %v, ожидается [2]", got)
+	}
+
+	// Новый результат запускает новый таймер
+	b.add(result(3))
+	select {
+	case <-sent.sent:
+	case <-time.After(time.Second):
+		t.Fatal("второй результат не отправлен по таймеру")
+	}
+}
+
+func TestResultBatcherFlushOnClose(t *testing.T) {
+	sent := newSentBatches()
+	b := newResultBatcher(100, time.Hour, sent.send)
+
+	b.add(result(1))
+	b.add(result(2))
+	if got := sent.sizes(); len(got) != 0 {
+		t.Fatalf("отправлены пакеты %v до close, ожидается ни одного", got)
+	}
+
+	b.close()
+	if got := sent.sizes(); !reflect.DeepEqual(got, []int{2}) {
+		t.Fatalf("после close отправлены пакеты %v, ожидается [2]", got)
+	}
+
+	// Воркер, закончивший задачу после close, отправляет результат сразу
+	b.add(result(3))
+	if got := sent.sizes(); !reflect.DeepEqual(got, []int{2, 1}) {
+		t.Errorf("отправлены пакеты %v, ожидается [2 1]", got)
+	}
+}
+
+func TestSubmitResultsPartialFailure(t *testing.T) {
+	var received types.TaskResultBatch
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/internal/tasks" {
+			t.Errorf("запрос %s %s, ожидается POST /internal/tasks", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		json.NewEncoder(w).Encode(types.TaskResultBatchResponse{Results: []types.TaskResultStatus{
+			{ID: "task-1", Accepted: true},
+			{ID: "task-2", ErrorCode: api.CodeTaskNotFound, Error: "Task not found"},
+		}})
+	}))
+	defer server.Close()
+
+	defer func(url string) { orchestratorBaseURL = url }(orchestratorBaseURL)
+	orchestratorBaseURL = server.URL
+
+	rejected, err := submitResults(context.Background(), []types.TaskResult{result(1), result(2)})
+	if err != nil {
+		t.Fatalf("ошибка отправки: %v", err)
+	}
+	if len(received.Results) != 2 {
+		t.Errorf("оркестратор получил %d результатов, ожидается 2", len(received.Results))
+	}
+	want := []types.TaskResultStatus{{ID: "task-2", ErrorCode: api.CodeTaskNotFound, Error: "Task not found"}}
+	if !reflect.DeepEqual(rejected, want) {
+		t.Errorf("непринятые результаты = %+v, ожидается %+v", rejected, want)
+	}
+}
+
+func TestSubmitResultsErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Request must contain from 1 to 100 results", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	defer func(url string) { orchestratorBaseURL = url }(orchestratorBaseURL)
+	orchestratorBaseURL = server.URL
+
+	if _, err := submitResults(context.Background(), []types.TaskResult{result(1)}); err == nil {
+		t.Error("ошибка отправки = nil, ожидается ошибка для статуса 400")
+	}
+}
+
+func TestSendResultsRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		want     int
+	}{
+		{"повтор после 5xx", []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK}, 3},
+		{"без повтора после 4xx", []int{http.StatusBadRequest}, 1},
+		{"не больше числа пауз", []int{500, 500, 500, 500}, 3},
+	}
+
+	defer func(delays []time.Duration) { resultRetryDelays = delays }(resultRetryDelays)
+	resultRetryDelays = []time.Duration{time.Millisecond, time.Millisecond}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[min(requests, len(tt.statuses)-1)]
+				requests++
+				if status != http.StatusOK {
+					http.Error(w, "unavailable", status)
+					return
+				}
+				json.NewEncoder(w).Encode(types.TaskResultBatchResponse{Results: []types.TaskResultStatus{{ID: "task-1", Accepted: true}}})
+			}))
+			defer server.Close()
+
+			defer func(url string) { orchestratorBaseURL = url }(orchestratorBaseURL)
+			orchestratorBaseURL = server.URL
+
+			sendResults(context.Background(), []types.TaskResult{result(1)})
+			if requests != tt.want {
+				t.Errorf("запросов = %d, ожидается %d", requests, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaskBatcherClose(t *testing.T) {
+	fetches := 0
+	b := newTaskBatcher(func(n int) ([]types.Task, int) {
+		fetches++
+		return []types.Task{{ID: "a"}, {ID: "b"}, {ID: "c"}}, types.ProtocolV4
+	})
+
+	if _, _, ok := b.take(3); !ok {
+		t.Fatal("задача не получена")
+	}
+	if dropped := b.close(); dropped != 2 {
+		t.Errorf("не начато задач = %d, ожидается 2", dropped)
+	}
+	if task, _, ok := b.take(3); ok {
+		t.Errorf("после close получена задача %s, ожидается ни одной", task.ID)
+	}
+	if fetches != 1 {
+		t.Errorf("запросов пакетов = %d, ожидается 1: после close задачи не запрашиваются", fetches)
+	}
+}
+
+func TestTaskBatcherSharesFetch(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		fetches []int
+	)
+	release := make(chan struct{})
+	b := newTaskBatcher(func(n int) ([]types.Task, int) {
+		mu.Lock()
+		fetches = append(fetches, n)
+		mu.Unlock()
+		<-release
+		return []types.Task{{ID: "a"}, {ID: "b"}, {ID: "c"}}, types.ProtocolV4
+	})
+
+	var wg sync.WaitGroup
+	taken := make(chan string, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if task, version, ok := b.take(3); ok && version == types.ProtocolV4 {
+				taken <- task.ID
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(taken)
+
+	got := map[string]bool{}
+	for id := range taken {
+		got[id] = true
+	}
+	if len(got) != 3 {
+		t.Errorf("воркеры получили задачи %v, ожидается a, b и c", got)
+	}
+	if !reflect.DeepEqual(fetches, []int{3}) {
+		t.Errorf("запросы пакетов %v, ожидается один запрос на 3 задачи", fetches)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "time"
+
+// processCPUTime недоступно: автомасштабирование работает только по очереди оркестратора
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
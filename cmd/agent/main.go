@@ -1,56 +1,75 @@
 package main
 
 import (
+	"calculator-service/internal/config"
+	"calculator-service/internal/logging"
+	"calculator-service/internal/tracing"
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
-	"strconv"
-	"sync"
+	"os/signal"
+	"syscall"
 
 	"github.com/joho/godotenv"
 )
 
+// Настройки, которые меняются только с перезапуском агента
 var (
-	TIME_ADDITION_MS        int
-	TIME_SUBTRACTION_MS     int
-	TIME_MULTIPLICATIONS_MS int
-	TIME_DIVISIONS_MS       int
-	COMPUTING_POWER         int
+	AGENT_PORT   string
+	AGENT_SECRET string
+	AGENT_ID     string
 )
 
 func loadConfig() {
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: .env file not found")
-	}
+	envErr := godotenv.Load()
 
-	var err error
-	TIME_ADDITION_MS, err = strconv.Atoi(getEnvOrDefault("TIME_ADDITION_MS", "1000"))
-	if err != nil {
-		log.Fatal("Invalid TIME_ADDITION_MS")
+	if err := logging.Setup(); err != nil {
+		log.Fatal(err)
+	}
+	if envErr != nil {
+		slog.Warn(".env file not found")
+	}
+	if err := tracing.Setup("agent"); err != nil {
+		log.Fatal(err)
 	}
 
-	TIME_SUBTRACTION_MS, err = strconv.Atoi(getEnvOrDefault("TIME_SUBTRACTION_MS", "1000"))
+	source, err := config.Load(os.Getenv(config.FileEnv))
 	if err != nil {
-		log.Fatal("Invalid TIME_SUBTRACTION_MS")
+		fatal("invalid configuration", err)
 	}
 
-	TIME_MULTIPLICATIONS_MS, err = strconv.Atoi(getEnvOrDefault("TIME_MULTIPLICATIONS_MS", "1000"))
-	if err != nil {
-		log.Fatal("Invalid TIME_MULTIPLICATIONS_MS")
+	AGENT_PORT = getOrDefault(source, "AGENT_PORT", "8081")
+
+	// По AGENT_ID оркестратор показывает агента в списке агентов, по умолчанию - имя хоста и PID
+	AGENT_ID = source.Get("AGENT_ID")
+	if AGENT_ID == "" {
+		hostname, _ := os.Hostname()
+		AGENT_ID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
 	}
 
-	TIME_DIVISIONS_MS, err = strconv.Atoi(getEnvOrDefault("TIME_DIVISIONS_MS", "1000"))
-	if err != nil {
-		log.Fatal("Invalid TIME_DIVISIONS_MS")
+	var errs []error
+	AGENT_SECRET = source.Get("AGENT_SECRET")
+	if AGENT_SECRET == "" {
+		errs = append(errs, errors.New("AGENT_SECRET must be set"))
 	}
 
-	COMPUTING_POWER, err = strconv.Atoi(getEnvOrDefault("COMPUTING_POWER", "4"))
-	if err != nil {
-		log.Fatal("Invalid COMPUTING_POWER")
+	s, err := loadSettings(source)
+	if err := errors.Join(append(errs, err)...); err != nil {
+		fatal("invalid configuration", err)
 	}
+	currentSettings.Store(&s)
 }
 
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+func getOrDefault(source config.Source, key, defaultValue string) string {
+	if value := source.Get(key); value != "" {
 		return value
 	}
 	return defaultValue
@@ -58,23 +77,43 @@ func getEnvOrDefault(key, defaultValue string) string {
 
 func main() {
 	loadConfig()
+	s := getSettings()
 
-	sem := make(chan struct{}, COMPUTING_POWER)
-	var wg sync.WaitGroup
-
-	log.Printf("Agent started with computing power: %d", COMPUTING_POWER)
-
-	for i := 0; i < COMPUTING_POWER; i++ {
-		wg.Add(1)
-		go func(workerID int) {
-			defer wg.Done()
-			for {
-				sem <- struct{}{}
-				processTask(workerID)
-				<-sem
-			}
-		}(i)
-	}
+	slog.Info("agent started", "agent_id", AGENT_ID, "capacity", s.capacity(), "autoscaling", s.autoscaling())
+
+	workersGauge.Set(0, "idle")
+	workersGauge.Set(0, "busy")
+
+	scaler := &autoscaler{pool: &workerPool{work: processTask}, cpuTime: processCPUTime}
+	scaler.apply(s)
+	go scaler.run()
+	startStatusServer(AGENT_PORT, scaler)
 
-	wg.Wait()
+	// По SIGINT и SIGTERM агент отправляет накопленные результаты и завершается
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	path := os.Getenv(config.FileEnv)
+	config.Watch(ctx, path, config.PollInterval, func() {
+		source, err := config.Load(path)
+		var reloaded settings
+		if err == nil {
+			reloaded, err = loadSettings(source)
+		}
+		if err != nil {
+			slog.Error("configuration reload failed, keeping current settings", "config_file", path, "error", err)
+			return
+		}
+
+		currentSettings.Store(&reloaded)
+		scaler.apply(&reloaded)
+		slog.Info("configuration reloaded", "config_file", path, "capacity", reloaded.capacity(), "autoscaling", reloaded.autoscaling())
+	})
+
+	// Полученные, но не начатые задачи оркестратор выдаст снова, когда истечёт их аренда
+	if dropped := batcher.close(); dropped > 0 {
+		slog.Warn("unstarted tasks left to the orchestrator", "tasks", dropped)
+	}
+	results.close()
+	slog.Info("agent stopped", "agent_id", AGENT_ID)
 }
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"calculator-service/internal/metrics"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+var (
+	registry = metrics.NewRegistry()
+
+	workersGauge = registry.NewGaugeVec("calc_agent_workers",
+		"Agent worker goroutines, by state.", "state")
+
+	workersTarget = registry.NewGaugeVec("calc_agent_workers_target",
+		"Number of workers chosen by the autoscaler or set by COMPUTING_POWER.")
+
+	cpuUsageGauge = registry.NewGaugeVec("calc_agent_cpu_usage",
+		"Share of total CPU time used by the agent over the last autoscaling interval.")
+
+	workerBusySeconds = registry.NewCounterVec("calc_agent_worker_busy_seconds_total",
+		"Total time workers spent computing tasks.")
+
+	computeSeconds = registry.NewHistogramVec("calc_agent_compute_seconds",
+		"Time spent computing a single task, by operation.", metrics.DefaultBuckets, "operation")
+
+	httpErrorsTotal = registry.NewCounterVec("calc_agent_http_errors_total",
+		"Failed requests to the orchestrator, by request and kind of failure.", "request", "kind")
+)
+
+// startStatusServer отдаёт метрики на /metrics и состояние воркеров на /status
+func startStatusServer(port string, scaler *autoscaler) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry.Handler())
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(scaler.status())
+	})
+
+	go func() {
+		slog.Info("agent metrics available", "url", "http://localhost:"+port+"/metrics", "status", "http://localhost:"+port+"/status")
+		if err := http.ListenAndServe(":"+port, mux); err != nil {
+			slog.Error("metrics server stopped", "error", err)
+		}
+	}()
+}
+
+func trackBusy(operation string, start time.Time) {
+	elapsed := time.Since(start).Seconds()
+	workersGauge.Add(-1, "busy")
+	workersGauge.Add(1, "idle")
+	workerBusySeconds.Add(elapsed)
+	computeSeconds.Observe(elapsed, operation)
+}
@@ -0,0 +1,46 @@
+package main
+
+import "sync"
+
+// workerPool - воркеры, которые запрашивают задачи у оркестратора. Размер меняется без перезапуска
+type workerPool struct {
+	// work - одна итерация воркера: получить задачу и выполнить её
+	work func(workerID int)
+
+	mu     sync.Mutex
+	stops  []chan struct{}
+	nextID int
+}
+
+// resize запускает недостающих воркеров или останавливает лишних.
+// Остановленный воркер сначала завершает текущую задачу
+func (p *workerPool) resize(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for len(p.stops) < n {
+		stop := make(chan struct{})
+		p.stops = append(p.stops, stop)
+		go p.runWorker(p.nextID, stop)
+		p.nextID++
+	}
+	for len(p.stops) > n {
+		last := len(p.stops) - 1
+		close(p.stops[last])
+		p.stops = p.stops[:last]
+	}
+}
+
+func (p *workerPool) runWorker(workerID int, stop <-chan struct{}) {
+	workersGauge.Add(1, "idle")
+	defer workersGauge.Add(-1, "idle")
+
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+			p.work(workerID)
+		}
+	}
+}
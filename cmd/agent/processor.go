@@ -2,95 +2,220 @@ package main
 
 import (
 	"bytes"
+	"calculator-service/internal/auth"
+	"calculator-service/internal/logging"
+	"calculator-service/internal/tracing"
 	"calculator-service/internal/types"
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
-const (
-	orchestratorBaseURL = "http://localhost:8080"
-)
+// orchestratorBaseURL - адрес оркестратора, тесты подменяют его адресом тестового сервера
+var orchestratorBaseURL = "http://localhost:8080"
+
+// orchestratorVersion - версия протокола из последнего ответа оркестратора. С версии 4
+// воркеры получают задачи и сдают результаты пакетами
+var orchestratorVersion atomic.Int64
 
 func processTask(workerID int) {
-	resp, err := http.Get(orchestratorBaseURL + "/internal/task")
+	if orchestratorVersion.Load() >= types.ProtocolV4 {
+		processBatchTask(workerID)
+		return
+	}
+
+	ctx := logging.With(context.Background(), "worker_id", workerID)
+
+	getReq, err := newOrchestratorRequest(http.MethodGet, "/internal/task", nil, types.ProtocolVersion)
 	if err != nil {
-		log.Printf("Worker %d: Error getting task: %v", workerID, err)
+		slog.ErrorContext(ctx, "error building task request", "error", err)
+		return
+	}
+
+	resp, err := http.DefaultClient.Do(getReq)
+	if err != nil {
+		httpErrorsTotal.Inc("get_task", "transport")
+		slog.ErrorContext(ctx, "error getting task", "error", err)
 		time.Sleep(time.Second)
 		return
 	}
 	defer resp.Body.Close()
 
+	if depth, err := strconv.Atoi(resp.Header.Get(types.QueueDepthHeader)); err == nil {
+		queueDepth.Store(int64(depth))
+	}
+
 	if resp.StatusCode == http.StatusNoContent {
+		if version, err := types.ParseProtocolVersion(resp.Header.Get(types.ProtocolVersionHeader)); err == nil {
+			orchestratorVersion.Store(int64(version))
+		}
 		time.Sleep(time.Second)
 		return
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("Worker %d: Unexpected status code: %d", workerID, resp.StatusCode)
+		httpErrorsTotal.Inc("get_task", "status")
+		slog.ErrorContext(ctx, "unexpected status code", "status", resp.StatusCode)
 		time.Sleep(time.Second)
 		return
 	}
 
+	// Оркестратор старой версии не возвращает заголовок, тогда работаем по версии 1
+	version, err := types.ParseProtocolVersion(resp.Header.Get(types.ProtocolVersionHeader))
+	if err != nil {
+		httpErrorsTotal.Inc("get_task", "protocol")
+		slog.ErrorContext(ctx, "invalid protocol version", "error", err)
+		return
+	}
+	version, _ = types.NegotiateProtocol(version)
+	orchestratorVersion.Store(int64(version))
+
 	var task types.Task
 	if err := json.NewDecoder(resp.Body).Decode(&task); err != nil {
-		log.Printf("Worker %d: Error decoding task: %v", workerID, err)
+		httpErrorsTotal.Inc("get_task", "decode")
+		slog.ErrorContext(ctx, "error decoding task", "error", err)
 		return
 	}
 
-	result := calculateResult(task)
+	ctx = taskContext(ctx, task)
+	taskResult, traceparent := execute(ctx, workerID, task, version, resp.Header.Get(tracing.TraceparentHeader))
 
-	taskResult := types.TaskResult{
-		ID:     task.ID,
-		Result: result,
+	resultJSON, err := json.Marshal(taskResult)
+	if err != nil {
+		slog.ErrorContext(ctx, "error marshaling result", "error", err)
+		return
 	}
 
-	resultJSON, err := json.Marshal(taskResult)
+	req, err := newOrchestratorRequest(http.MethodPost, "/internal/task", resultJSON, version)
 	if err != nil {
-		log.Printf("Worker %d: Error marshaling result: %v", workerID, err)
+		slog.ErrorContext(ctx, "error building result request", "error", err)
 		return
 	}
+	if task.RequestID != "" {
+		req.Header.Set(logging.RequestIDHeader, task.RequestID)
+	}
+	req.Header.Set(tracing.TraceparentHeader, traceparent)
 
-	resp, err = http.Post(orchestratorBaseURL+"/internal/task", "application/json", bytes.NewBuffer(resultJSON))
+	resp, err = http.DefaultClient.Do(req)
 	if err != nil {
-		log.Printf("Worker %d: Error sending result: %v", workerID, err)
+		httpErrorsTotal.Inc("submit_result", "transport")
+		slog.ErrorContext(ctx, "error sending result", "error", err)
 		return
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("Worker %d: Error response when sending result: %d", workerID, resp.StatusCode)
+		httpErrorsTotal.Inc("submit_result", "status")
+		slog.ErrorContext(ctx, "error response when sending result", "status", resp.StatusCode)
+		return
+	}
+
+	slog.InfoContext(ctx, "task completed", "operation", task.Operation, "result", taskResult.Result)
+}
+
+// newOrchestratorRequest готовит запрос к внутренним endpoints оркестратора. Запрос задач
+// сообщает ёмкость и возможности агента, тело отправляется как JSON
+func newOrchestratorRequest(method, path string, body []byte, version int) (*http.Request, error) {
+	req, err := http.NewRequest(method, orchestratorBaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(auth.AgentSecretHeader, AGENT_SECRET)
+	req.Header.Set(types.AgentIDHeader, AGENT_ID)
+	req.Header.Set(types.ProtocolVersionHeader, strconv.Itoa(version))
+	if method == http.MethodGet {
+		s := getSettings()
+		req.Header.Set(types.AgentCapacityHeader, strconv.Itoa(s.capacity()))
+		req.Header.Set(types.AgentCapabilitiesHeader, strings.Join(s.capabilities, ","))
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+func taskContext(ctx context.Context, task types.Task) context.Context {
+	ctx = logging.With(logging.WithRequestID(ctx, task.RequestID),
+		"expression_id", task.ExpressionID,
+		"task_id", task.ID)
+	slog.DebugContext(ctx, "task received", "operation", task.Operation)
+	return ctx
+}
+
+// execute вычисляет задачу и возвращает результат для оркестратора вместе с контекстом
+// трассировки вычисления. traceparent - контекст, переданный оркестратором при выдаче задачи
+func execute(ctx context.Context, workerID int, task types.Task, version int, traceparent string) (types.TaskResult, string) {
+	parent, _ := tracing.ParseTraceparent(traceparent)
+	span := tracing.Start("agent.compute", parent)
+	span.SetAttr("expression_id", task.ExpressionID)
+	span.SetAttr("task_id", task.ID)
+	span.SetAttr("operation", task.Operation)
+	span.SetAttr("worker_id", workerID)
+
+	workersGauge.Add(1, "busy")
+	workersGauge.Add(-1, "idle")
+	start := time.Now()
+	result, calcErr := calculateResult(task, operationDelay(task, version))
+	trackBusy(task.Operation, start)
+	span.End()
+
+	taskResult := types.TaskResult{
+		ID:     task.ID,
+		Result: result,
 	}
+	if calcErr != nil {
+		slog.WarnContext(ctx, "task failed", "operation", task.Operation, "error", calcErr)
+		// По версии 1 оркестратор не принимает ошибку и получает 0, как раньше
+		if version >= types.ProtocolV2 {
+			taskResult.Error = calcErr.Error()
+		}
+	}
+	return taskResult, span.Context().Traceparent()
 }
 
-func calculateResult(task types.Task) float64 {
-	var delay time.Duration
+// operationDelay - время выполнения задачи. С версии протокола 3 его задаёт оркестратор,
+// с оркестратором старой версии агент берёт время из своих TIME_*_MS
+func operationDelay(task types.Task, version int) time.Duration {
+	if version >= types.ProtocolV3 {
+		return time.Duration(task.OperationTime) * time.Millisecond
+	}
+
+	s := getSettings()
 	switch task.Operation {
 	case "+":
-		delay = time.Duration(TIME_ADDITION_MS) * time.Millisecond
+		return s.additionTime
 	case "-":
-		delay = time.Duration(TIME_SUBTRACTION_MS) * time.Millisecond
+		return s.subtractionTime
 	case "*":
-		delay = time.Duration(TIME_MULTIPLICATIONS_MS) * time.Millisecond
+		return s.multiplicationTime
 	case "/":
-		delay = time.Duration(TIME_DIVISIONS_MS) * time.Millisecond
+		return s.divisionTime
 	}
+	return 0
+}
+
+func calculateResult(task types.Task, delay time.Duration) (float64, error) {
 	time.Sleep(delay)
 
 	switch task.Operation {
 	case "+":
-		return task.Arg1 + task.Arg2
+		return task.Arg1 + task.Arg2, nil
 	case "-":
-		return task.Arg1 - task.Arg2
+		return task.Arg1 - task.Arg2, nil
 	case "*":
-		return task.Arg1 * task.Arg2
+		return task.Arg1 * task.Arg2, nil
 	case "/":
 		if task.Arg2 == 0 {
-			return 0
+			return 0, errors.New("division by zero")
 		}
-		return task.Arg1 / task.Arg2
+		return task.Arg1 / task.Arg2, nil
 	default:
-		return 0
+		return 0, fmt.Errorf("unsupported operation %q", task.Operation)
 	}
 }
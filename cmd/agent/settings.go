@@ -0,0 +1,140 @@
+package main
+
+import (
+	"calculator-service/internal/config"
+	"calculator-service/internal/types"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// settings - настройки агента, которые применяются без перезапуска:
+// время операций для оркестратора старой версии, число воркеров и возможности агента
+type settings struct {
+	additionTime       time.Duration
+	subtractionTime    time.Duration
+	multiplicationTime time.Duration
+	divisionTime       time.Duration
+	computingPower     int
+
+	// Автомасштабирование включено, если задан maxWorkers. Тогда computingPower не используется
+	minWorkers        int
+	maxWorkers        int
+	cpuLimit          float64
+	autoscaleInterval time.Duration
+
+	// capabilities - операции, задачи для которых агент получает от оркестратора
+	capabilities []string
+}
+
+func (s *settings) autoscaling() bool {
+	return s.maxWorkers > 0
+}
+
+// capacity - сколько задач агент может выполнять одновременно
+func (s *settings) capacity() int {
+	if s.autoscaling() {
+		return s.maxWorkers
+	}
+	return s.computingPower
+}
+
+var currentSettings atomic.Pointer[settings]
+
+func getSettings() *settings {
+	return currentSettings.Load()
+}
+
+// loadSettings читает TIME_ADDITION_MS, TIME_SUBTRACTION_MS, TIME_MULTIPLICATIONS_MS,
+// TIME_DIVISIONS_MS, COMPUTING_POWER, MIN_WORKERS, MAX_WORKERS, AUTOSCALE_CPU_LIMIT,
+// AUTOSCALE_INTERVAL и AGENT_CAPABILITIES. Возвращает все ошибки сразу
+func loadSettings(source config.Source) (settings, error) {
+	s := settings{
+		additionTime:       time.Second,
+		subtractionTime:    time.Second,
+		multiplicationTime: time.Second,
+		divisionTime:       time.Second,
+		computingPower:     4,
+		minWorkers:         1,
+		cpuLimit:           0.8,
+		autoscaleInterval:  5 * time.Second,
+		capabilities:       types.BasicOperations,
+	}
+	var errs []error
+
+	times := []struct {
+		key string
+		dst *time.Duration
+	}{
+		{"TIME_ADDITION_MS", &s.additionTime},
+		{"TIME_SUBTRACTION_MS", &s.subtractionTime},
+		{"TIME_MULTIPLICATIONS_MS", &s.multiplicationTime},
+		{"TIME_DIVISIONS_MS", &s.divisionTime},
+	}
+	for _, v := range times {
+		raw := source.Get(v.key)
+		if raw == "" {
+			continue
+		}
+		ms, err := strconv.Atoi(raw)
+		if err != nil || ms < 0 || ms > types.MaxOperationTimeMs {
+			errs = append(errs, fmt.Errorf("invalid %s: %q, expected a number of milliseconds from 0 to %d", v.key, raw, types.MaxOperationTimeMs))
+			continue
+		}
+		*v.dst = time.Duration(ms) * time.Millisecond
+	}
+
+	workers := []struct {
+		key string
+		dst *int
+	}{
+		{"COMPUTING_POWER", &s.computingPower},
+		{"MIN_WORKERS", &s.minWorkers},
+		{"MAX_WORKERS", &s.maxWorkers},
+	}
+	for _, v := range workers {
+		raw := source.Get(v.key)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			errs = append(errs, fmt.Errorf("invalid %s: %q, expected a positive integer", v.key, raw))
+			continue
+		}
+		*v.dst = n
+	}
+	if s.autoscaling() && s.minWorkers > s.maxWorkers {
+		errs = append(errs, fmt.Errorf("MIN_WORKERS (%d) must not exceed MAX_WORKERS (%d)", s.minWorkers, s.maxWorkers))
+	}
+
+	if raw := source.Get("AUTOSCALE_CPU_LIMIT"); raw != "" {
+		limit, err := strconv.ParseFloat(raw, 64)
+		if err != nil || limit <= 0 || limit > 1 {
+			errs = append(errs, fmt.Errorf("invalid AUTOSCALE_CPU_LIMIT: %q, expected a share of CPU between 0 and 1", raw))
+		} else {
+			s.cpuLimit = limit
+		}
+	}
+
+	if raw := source.Get("AUTOSCALE_INTERVAL"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("invalid AUTOSCALE_INTERVAL: %q, expected a positive duration such as 5s", raw))
+		} else {
+			s.autoscaleInterval = d
+		}
+	}
+
+	if raw := source.Get("AGENT_CAPABILITIES"); raw != "" {
+		if capabilities := types.ParseCapabilities(raw); len(capabilities) > 0 {
+			s.capabilities = capabilities
+		} else {
+			errs = append(errs, fmt.Errorf("invalid AGENT_CAPABILITIES: %q, expected a comma-separated list such as +,-,*,/", raw))
+		}
+	}
+
+	return s, errors.Join(errs...)
+}
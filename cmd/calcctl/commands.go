@@ -0,0 +1,195 @@
+package main
+
+import (
+	"calculator-service/internal/client"
+	"calculator-service/internal/types"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+func submitCommand() command {
+	var wait time.Duration
+	var file string
+	var interval time.Duration
+
+	return command{
+		flags: func(fs *flag.FlagSet) {
+			fs.DurationVar(&wait, "wait", 0, "ждать результата не дольше указанного времени (например, 30s)")
+			fs.StringVar(&file, "file", "", "файл с выражениями, по одному в строке (- для stdin)")
+			fs.DurationVar(&interval, "interval", time.Second, "период опроса пакета при -wait")
+		},
+		run: func(ctx context.Context, c *client.Client, p *printer, fs *flag.FlagSet) error {
+			var expressions []string
+			switch {
+			case fs.NArg() > 0 && file != "":
+				return usageError("pass an expression or -file, not both")
+			case fs.NArg() > 0:
+				expressions = []string{strings.Join(fs.Args(), " ")}
+			default:
+				input := p.stdin
+				if file != "" && file != "-" {
+					f, err := os.Open(file)
+					if err != nil {
+						return err
+					}
+					defer f.Close()
+					input = f
+				}
+				var err error
+				if expressions, err = readExpressions(input); err != nil {
+					return err
+				}
+			}
+
+			switch len(expressions) {
+			case 0:
+				return usageError("no expressions to submit")
+			case 1:
+				expr, err := c.Submit(ctx, expressions[0], wait)
+				if err != nil {
+					return err
+				}
+				return p.expressions([]types.Expression{expr})
+			}
+
+			batch, err := c.SubmitBatch(ctx, expressions)
+			if err != nil {
+				return err
+			}
+			if wait > 0 {
+				if batch, err = waitBatch(ctx, c, batch, wait, interval); err != nil {
+					return err
+				}
+			}
+			return p.batch(batch)
+		},
+	}
+}
+
+// waitBatch опрашивает пакет, пока все его выражения не завершатся или не истечёт wait
+func waitBatch(ctx context.Context, c *client.Client, batch types.Batch, wait, interval time.Duration) (types.Batch, error) {
+	deadline := time.Now().Add(wait)
+	for batch.Status == types.StatusProcessing && time.Now().Before(deadline) {
+		if err := sleepContext(ctx, min(interval, time.Until(deadline))); err != nil {
+			return batch, err
+		}
+		var err error
+		if batch, err = c.Batch(ctx, batch.ID); err != nil {
+			return batch, err
+		}
+	}
+	return batch, nil
+}
+
+func runGet(ctx context.Context, c *client.Client, p *printer, fs *flag.FlagSet) error {
+	if fs.NArg() == 0 {
+		return usageError("get requires at least one expression id")
+	}
+
+	list := make([]types.Expression, 0, fs.NArg())
+	for _, id := range fs.Args() {
+		expr, err := c.Get(ctx, id)
+		if err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		list = append(list, expr)
+	}
+	return p.expressions(list)
+}
+
+func listCommand() command {
+	var opts client.ListOptions
+	var from, to string
+	var all bool
+
+	return command{
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&opts.Status, "status", "", "статусы через запятую: PROCESSING, COMPLETED, ERROR")
+			fs.StringVar(&from, "from", "", "созданные не раньше (RFC 3339)")
+			fs.StringVar(&to, "to", "", "созданные раньше (RFC 3339)")
+			fs.StringVar(&opts.Sort, "sort", "", "сортировка: created_at или completed_at")
+			fs.StringVar(&opts.Order, "order", "", "порядок: desc или asc")
+			fs.IntVar(&opts.Limit, "limit", 0, "размер страницы")
+			fs.StringVar(&opts.Cursor, "cursor", "", "курсор следующей страницы")
+			fs.BoolVar(&all, "all", false, "получить все страницы")
+		},
+		run: func(ctx context.Context, c *client.Client, p *printer, fs *flag.FlagSet) error {
+			var err error
+			if from != "" {
+				if opts.CreatedFrom, err = time.Parse(time.RFC3339, from); err != nil {
+					return usageError("-from must be an RFC 3339 timestamp")
+				}
+			}
+			if to != "" {
+				if opts.CreatedTo, err = time.Parse(time.RFC3339, to); err != nil {
+					return usageError("-to must be an RFC 3339 timestamp")
+				}
+			}
+
+			var result []types.Expression
+			for {
+				page, err := c.List(ctx, opts)
+				if err != nil {
+					return err
+				}
+				result = append(result, page.Expressions...)
+				opts.Cursor = page.NextCursor
+				if !all || page.NextCursor == "" {
+					break
+				}
+			}
+			return p.list(result, opts.Cursor)
+		},
+	}
+}
+
+func watchCommand() command {
+	var interval time.Duration
+
+	return command{
+		flags: func(fs *flag.FlagSet) {
+			fs.DurationVar(&interval, "interval", 500*time.Millisecond, "период опроса")
+		},
+		run: func(ctx context.Context, c *client.Client, p *printer, fs *flag.FlagSet) error {
+			if fs.NArg() != 1 {
+				return usageError("watch requires exactly one expression id")
+			}
+			if interval <= 0 {
+				return usageError("-interval must be positive")
+			}
+
+			_, err := c.Watch(ctx, fs.Arg(0), interval, func(expr types.Expression) {
+				p.progress(expr)
+			})
+			return err
+		},
+	}
+}
+
+func runCancel(ctx context.Context, c *client.Client, p *printer, fs *flag.FlagSet) error {
+	if fs.NArg() == 0 {
+		return usageError("cancel requires at least one expression id")
+	}
+
+	list := make([]types.Expression, 0, fs.NArg())
+	for _, id := range fs.Args() {
+		expr, err := c.Cancel(ctx, id)
+		if err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		list = append(list, expr)
+	}
+	return p.expressions(list)
+}
+
+func runAgents(ctx context.Context, c *client.Client, p *printer, fs *flag.FlagSet) error {
+	agents, err := c.Agents(ctx)
+	if err != nil {
+		return err
+	}
+	return p.agents(agents)
+}
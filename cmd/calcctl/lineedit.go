@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// errInterrupted - строка прервана по Ctrl+C
+var errInterrupted = errors.New("interrupted")
+
+// lineEditor читает строку в посимвольном режиме терминала: стрелки, история, дополнение по Tab
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	history  []string
+	complete func(word string) []string
+}
+
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	var line []rune
+	pos := 0
+	histPos := len(e.history)
+	draft := ""
+
+	e.refresh(prompt, line, pos)
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\n")
+			return string(line), nil
+		case 3: // Ctrl+C
+			fmt.Fprint(e.out, "^C\n")
+			return "", errInterrupted
+		case 4: // Ctrl+D
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\n")
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = append(line[:pos], line[pos+1:]...)
+			}
+		case 127, 8: // Backspace
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+			}
+		case 1: // Ctrl+A
+			pos = 0
+		case 5: // Ctrl+E
+			pos = len(line)
+		case 21: // Ctrl+U
+			line, pos = line[pos:], 0
+		case 11: // Ctrl+K
+			line = line[:pos]
+		case '\t':
+			line, pos = e.completeWord(prompt, line, pos)
+		case 27: // ESC-последовательности стрелок, Home, End и Delete
+			switch e.readEscape() {
+			case "A":
+				if histPos > 0 {
+					if histPos == len(e.history) {
+						draft = string(line)
+					}
+					histPos--
+					line = []rune(e.history[histPos])
+					pos = len(line)
+				}
+			case "B":
+				if histPos < len(e.history) {
+					histPos++
+					if histPos == len(e.history) {
+						line = []rune(draft)
+					} else {
+						line = []rune(e.history[histPos])
+					}
+					pos = len(line)
+				}
+			case "C":
+				pos = min(pos+1, len(line))
+			case "D":
+				pos = max(pos-1, 0)
+			case "H", "1~":
+				pos = 0
+			case "F", "4~":
+				pos = len(line)
+			case "3~":
+				if pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+				}
+			}
+		default:
+			if unicode.IsPrint(r) {
+				line = append(line[:pos], append([]rune{r}, line[pos:]...)...)
+				pos++
+			}
+		}
+		e.refresh(prompt, line, pos)
+	}
+}
+
+// readEscape читает хвост последовательности ESC [ ... и возвращает его без префикса
+func (e *lineEditor) readEscape() string {
+	if r, _, err := e.in.ReadRune(); err != nil || (r != '[' && r != 'O') {
+		return ""
+	}
+	var seq []rune
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return ""
+		}
+		seq = append(seq, r)
+		if r >= 0x40 && r <= 0x7e {
+			return string(seq)
+		}
+	}
+}
+
+func (e *lineEditor) refresh(prompt string, line []rune, pos int) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(line))
+	if back := len(line) - pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+// completeWord дополняет слово перед курсором. При нескольких вариантах дописывает общее начало,
+// а если дописать нечего - выводит варианты
+func (e *lineEditor) completeWord(prompt string, line []rune, pos int) ([]rune, int) {
+	start := pos
+	for start > 0 && isWordRune(line[start-1]) {
+		start--
+	}
+	if start > 0 && line[start-1] == ':' {
+		start--
+	}
+	word := string(line[start:pos])
+
+	candidates := e.complete(word)
+	if len(candidates) == 0 {
+		fmt.Fprint(e.out, "\a")
+		return line, pos
+	}
+
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(candidates) == 1 && strings.HasPrefix(prefix, ":") {
+		prefix += " "
+	}
+
+	if len(prefix) == len(word) {
+		fmt.Fprintf(e.out, "\n%s\n", strings.Join(candidates, "  "))
+		return line, pos
+	}
+
+	insert := []rune(prefix[len(word):])
+	line = append(line[:pos], append(insert, line[pos:]...)...)
+	return line, pos + len(insert)
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"calculator-service/internal/client"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+const usage = `calcctl - клиент распределённого калькулятора
+
+Использование:
+  calcctl <команда> [флаги] [аргументы]
+
+Команды:
+  submit [выражение]   отправить выражение; без аргумента выражения читаются из -file или stdin, по одному в строке
+  get <id>...          показать выражения
+  list                 список выражений с фильтрами
+  watch <id>           следить за выполнением выражения
+  cancel <id>...       отменить выражения
+  agents               список агентов
+  repl                 интерактивный локальный калькулятор с переменными (без сервера)
+
+Общие флаги (у всех команд):
+  -server   адрес оркестратора (CALC_SERVER, по умолчанию http://localhost:8080)
+  -api-key  API-ключ из /api/v1/login (CALC_API_KEY)
+  -o        формат вывода: table или json
+
+Подробнее о флагах команды: calcctl <команда> -h
+`
+
+// options - общие флаги всех команд
+type options struct {
+	server string
+	apiKey string
+	output string
+}
+
+func (o *options) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.server, "server", envOrDefault("CALC_SERVER", "http://localhost:8080"), "адрес оркестратора")
+	fs.StringVar(&o.apiKey, "api-key", os.Getenv("CALC_API_KEY"), "API-ключ")
+	fs.StringVar(&o.output, "o", "table", "формат вывода: table или json")
+}
+
+func (o *options) printer(w io.Writer) (*printer, error) {
+	if o.output != "table" && o.output != "json" {
+		return nil, fmt.Errorf("unknown output format %q, use table or json", o.output)
+	}
+	return &printer{w: w, json: o.output == "json"}, nil
+}
+
+type command struct {
+	run func(ctx context.Context, c *client.Client, p *printer, fs *flag.FlagSet) error
+	// flags регистрирует собственные флаги команды
+	flags func(fs *flag.FlagSet)
+}
+
+var commands = map[string]command{
+	"submit": submitCommand(),
+	"get":    {run: runGet},
+	"list":   listCommand(),
+	"watch":  watchCommand(),
+	"cancel": {run: runCancel},
+	"agents": {run: runAgents},
+	"repl":   replCommand(),
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run возвращает код завершения: 0 - успех, 1 - ошибка запроса, 2 - ошибка в аргументах
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "calcctl: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("calcctl "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var opts options
+	opts.register(fs)
+	if cmd.flags != nil {
+		cmd.flags(fs)
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if name, ok := misplacedFlag(fs); ok {
+		fmt.Fprintf(stderr, "calcctl: flag %s must come before the arguments\n", name)
+		return 2
+	}
+
+	p, err := opts.printer(stdout)
+	if err != nil {
+		fmt.Fprintln(stderr, "calcctl:", err)
+		return 2
+	}
+	p.stdin = stdin
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := cmd.run(ctx, client.New(opts.server, opts.apiKey), p, fs); err != nil {
+		fmt.Fprintln(stderr, "calcctl:", err)
+		var usageErr usageError
+		if errors.As(err, &usageErr) {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// misplacedFlag находит флаг команды среди аргументов. flag прекращает разбор на первом
+// аргументе, и "submit 1+2 -wait 10s" иначе отправил бы "-wait 10s" как часть выражения.
+// Аргументы вроде "-3", которые не совпадают с флагами, остаются частью выражения
+func misplacedFlag(fs *flag.FlagSet) (string, bool) {
+	for _, arg := range fs.Args() {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if fs.Lookup(name) != nil {
+			return arg, true
+		}
+	}
+	return "", false
+}
+
+// usageError - ошибка в аргументах команды
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+func envOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// readExpressions читает выражения по одному в строке, пропуская пустые строки и комментарии #
+func readExpressions(r io.Reader) ([]string, error) {
+	var expressions []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		expressions = append(expressions, line)
+	}
+	return expressions, scanner.Err()
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
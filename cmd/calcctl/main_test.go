@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"calculator-service/internal/types"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSubmitFlagsAfterExpression(t *testing.T) {
+	var submitted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req types.CalculateRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		submitted = append(submitted, req.Expression)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": "expr-1"})
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantSent string
+	}{
+		{"флаг после выражения", []string{"submit", "-server", server.URL, "1+2", "--wait", "10s"}, 2, ""},
+		{"флаг со значением через =", []string{"submit", "-server", server.URL, "1+2", "-wait=10s"}, 2, ""},
+		{"общий флаг после выражения", []string{"submit", "1+2", "-o", "json"}, 2, ""},
+		{"флаги перед выражением", []string{"submit", "-server", server.URL, "-wait", "10s", "1+2"}, 0, "1+2"},
+		{"отрицательное число в выражении", []string{"submit", "-server", server.URL, "1+2", "-3"}, 0, "1+2 -3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			submitted = nil
+			var stdout, stderr bytes.Buffer
+			code := run(tt.args, strings.NewReader(""), &stdout, &stderr)
+
+			if code != tt.wantCode {
+				t.Fatalf("код завершения = %d, ожидается %d: %s", code, tt.wantCode, stderr.String())
+			}
+			if tt.wantCode == 2 {
+				if len(submitted) != 0 {
+					t.Errorf("отправлено %q, ожидается без запроса к серверу", submitted)
+				}
+				if !strings.Contains(stderr.String(), "must come before the arguments") {
+					t.Errorf("stderr = %q, ожидается подсказка о порядке флагов", stderr.String())
+				}
+				return
+			}
+			if len(submitted) != 1 || submitted[0] != tt.wantSent {
+				t.Errorf("отправлено %q, ожидается %q", submitted, tt.wantSent)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"calculator-service/internal/types"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// printer выводит ответы в виде таблицы или JSON (-o json)
+type printer struct {
+	w     io.Writer
+	stdin io.Reader
+	json  bool
+}
+
+func (p *printer) encode(v any) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (p *printer) table(header string, rows func(w io.Writer)) error {
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, header)
+	rows(tw)
+	return tw.Flush()
+}
+
+func (p *printer) expressions(list []types.Expression) error {
+	if p.json {
+		if len(list) == 1 {
+			return p.encode(list[0])
+		}
+		return p.encode(list)
+	}
+	return p.table("ID\tSTATUS\tRESULT\tEXPRESSION", func(w io.Writer) {
+		for _, expr := range list {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", expr.ID, expr.Status, resultText(expr.Status, expr.Result, expr.Error), expr.Original)
+		}
+	})
+}
+
+func (p *printer) list(list []types.Expression, nextCursor string) error {
+	if p.json {
+		return p.encode(types.ExpressionResponse{Expressions: list, NextCursor: nextCursor})
+	}
+	err := p.table("ID\tSTATUS\tRESULT\tCREATED\tEXPRESSION", func(w io.Writer) {
+		for _, expr := range list {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", expr.ID, expr.Status, resultText(expr.Status, expr.Result, expr.Error),
+				expr.CreatedAt.Local().Format(time.DateTime), expr.Original)
+		}
+	})
+	if err == nil && nextCursor != "" {
+		_, err = fmt.Fprintf(p.w, "\nnext page: -cursor %s\n", nextCursor)
+	}
+	return err
+}
+
+func (p *printer) batch(batch types.Batch) error {
+	if p.json {
+		return p.encode(batch)
+	}
+	err := p.table("KEY\tID\tSTATUS\tRESULT\tEXPRESSION", func(w io.Writer) {
+		for _, item := range batch.Items {
+			status, result := item.Status, "-"
+			switch {
+			case item.Error != "":
+				status, result = "REJECTED", item.Error
+			case item.Result != nil:
+				result = formatNumber(*item.Result)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", item.Key, item.ID, status, result, item.Expression)
+		}
+	})
+	if err == nil {
+		_, err = fmt.Fprintf(p.w, "\nbatch %s: %s, %d completed, %d processing, %d failed, %d rejected\n",
+			batch.ID, batch.Status, batch.Completed, batch.Processing, batch.Failed, batch.Rejected)
+	}
+	return err
+}
+
+// progress выводит одно состояние выражения в watch. В JSON каждое состояние - отдельная строка
+func (p *printer) progress(expr types.Expression) {
+	if p.json {
+		json.NewEncoder(p.w).Encode(expr)
+		return
+	}
+
+	line := fmt.Sprintf("%s  %-10s", time.Now().Format(time.TimeOnly), expr.Status)
+	if expr.Metrics != nil {
+		line += fmt.Sprintf("  tasks %d/%d  elapsed %s", expr.Metrics.TasksCompleted, expr.Metrics.Tasks,
+			(time.Duration(expr.Metrics.WallTimeMs) * time.Millisecond).String())
+	}
+	if expr.Status != types.StatusProcessing {
+		line += "  result " + resultText(expr.Status, expr.Result, expr.Error)
+	}
+	fmt.Fprintln(p.w, line)
+}
+
+func (p *printer) agents(agents []types.Agent) error {
+	if p.json {
+		return p.encode(types.AgentList{Agents: agents})
+	}
+	return p.table("ID\tSTATUS\tPROTOCOL\tCAPABILITIES\tIN PROGRESS\tCOMPLETED\tFAILED\tLAST SEEN", func(w io.Writer) {
+		for _, agent := range agents {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\t%d\t%d\t%s ago\n", agent.ID, agent.Status, agent.ProtocolVersion,
+				strings.Join(agent.Capabilities, ","), agent.TasksInProgress, agent.TasksCompleted, agent.TasksFailed, time.Since(agent.LastSeenAt).Round(time.Second))
+		}
+	})
+}
+
+func resultText(status string, result float64, errText string) string {
+	switch status {
+	case types.StatusCompleted:
+		return formatNumber(result)
+	case types.StatusError:
+		if errText != "" {
+			return "error: " + errText
+		}
+		return "error"
+	default:
+		return "-"
+	}
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
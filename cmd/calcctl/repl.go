@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"calculator-service/internal/client"
+	"calculator-service/internal/repl"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	replPrompt      = "calc> "
+	replHistorySize = 1000
+)
+
+func replCommand() command {
+	var historyFile string
+
+	return command{
+		flags: func(fs *flag.FlagSet) {
+			defaultHistory := ""
+			if home, err := os.UserHomeDir(); err == nil {
+				defaultHistory = filepath.Join(home, ".calcctl_history")
+			}
+			fs.StringVar(&historyFile, "history", defaultHistory, "файл истории ввода (пусто - не сохранять)")
+		},
+		run: func(ctx context.Context, _ *client.Client, p *printer, fs *flag.FlagSet) error {
+			session := repl.NewSession()
+			in := bufio.NewReader(p.stdin)
+
+			// В терминале включаем редактирование строки, из файла или канала просто читаем строки
+			var editor *lineEditor
+			if f, ok := p.stdin.(*os.File); ok {
+				if restore, err := makeRaw(f.Fd()); err == nil {
+					defer restore()
+					editor = &lineEditor{in: in, out: p.w, history: loadHistory(historyFile), complete: session.Complete}
+					fmt.Fprintln(p.w, "Local calculator. :help for commands, Ctrl+D to exit")
+				}
+			}
+
+			for ctx.Err() == nil {
+				var line string
+				var err error
+				if editor != nil {
+					line, err = editor.readLine(replPrompt)
+				} else {
+					line, err = in.ReadString('\n')
+					if err == io.EOF && line != "" {
+						err = nil
+					}
+				}
+				if errors.Is(err, errInterrupted) {
+					continue
+				}
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+
+				out, err := session.Execute(line)
+				if errors.Is(err, repl.ErrQuit) {
+					return nil
+				}
+				if editor != nil && strings.TrimSpace(line) != "" {
+					editor.history = append(editor.history, strings.TrimSpace(line))
+					appendHistory(historyFile, strings.TrimSpace(line))
+				}
+
+				if err != nil {
+					out = "error: " + err.Error()
+				}
+				if out != "" {
+					fmt.Fprintln(p.w, out)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// loadHistory читает последние строки файла истории
+func loadHistory(path string) []string {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	if len(lines) > replHistorySize {
+		lines = lines[len(lines)-replHistorySize:]
+	}
+	return lines
+}
+
+func appendHistory(path, line string) {
+	if path == "" {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
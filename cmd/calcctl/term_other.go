@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// На других системах REPL работает без редактирования строки: история и дополнение по Tab недоступны
+func makeRaw(fd uintptr) (func(), error) {
+	return nil, errors.New("raw terminal mode is supported only on linux")
+}
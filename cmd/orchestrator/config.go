@@ -0,0 +1,64 @@
+package main
+
+import (
+	"calculator-service/internal/config"
+	"calculator-service/internal/orchestrator"
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+)
+
+// settings - настройки оркестратора, которые применяются без перезапуска
+type settings struct {
+	limits         orchestrator.Limits
+	webhooks       orchestrator.WebhookConfig
+	operationTimes orchestrator.OperationTimes
+}
+
+// loadSettings проверяет все настройки и возвращает все найденные ошибки разом
+func loadSettings(source config.Source) (settings, error) {
+	var s settings
+	var limitsErr, webhooksErr, timesErr error
+	s.limits, limitsErr = orchestrator.LoadLimits(source.Get)
+	s.webhooks, webhooksErr = orchestrator.LoadWebhookConfig(source.Get)
+	s.operationTimes, timesErr = orchestrator.LoadOperationTimes(source.Get)
+	return s, errors.Join(limitsErr, webhooksErr, timesErr)
+}
+
+// apply применяет разделы, которые отличаются от prev. Неизменные разделы не трогаются:
+// SetLimits сбрасывает ограничение частоты запросов, а время операций могли изменить через /admin
+func (s settings) apply(prev *settings) {
+	if prev == nil || s.limits != prev.limits {
+		orchestrator.SetLimits(s.limits)
+	}
+	if prev == nil || !reflect.DeepEqual(s.webhooks, prev.webhooks) {
+		if s.webhooks.Secret == "" {
+			slog.Warn("WEBHOOK_SECRET is not set, callback_url is disabled")
+		}
+		orchestrator.SetWebhookConfig(s.webhooks)
+	}
+	if prev == nil || s.operationTimes != prev.operationTimes {
+		orchestrator.SetOperationTimes(s.operationTimes)
+	}
+}
+
+// watchSettings перечитывает CONFIG_FILE по SIGHUP и при изменении файла.
+// При ошибке в новых настройках продолжают действовать текущие
+func watchSettings(path string, current settings) {
+	config.Watch(context.Background(), path, config.PollInterval, func() {
+		source, err := config.Load(path)
+		var reloaded settings
+		if err == nil {
+			reloaded, err = loadSettings(source)
+		}
+		if err != nil {
+			slog.Error("configuration reload failed, keeping current settings", "config_file", path, "error", err)
+			return
+		}
+
+		reloaded.apply(&current)
+		current = reloaded
+		slog.Info("configuration reloaded", "config_file", path)
+	})
+}
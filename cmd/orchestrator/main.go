@@ -1,8 +1,14 @@
 package main
 
 import (
+	"calculator-service/internal/auth"
+	"calculator-service/internal/config"
+	"calculator-service/internal/logging"
+	"calculator-service/internal/openapi"
 	"calculator-service/internal/orchestrator"
+	"calculator-service/internal/tracing"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 
@@ -11,23 +17,77 @@ import (
 )
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: .env file not found")
+	envErr := godotenv.Load()
+
+	if err := logging.Setup(); err != nil {
+		log.Fatal(err)
+	}
+	if envErr != nil {
+		slog.Warn(".env file not found")
+	}
+	if err := tracing.Setup("orchestrator"); err != nil {
+		log.Fatal(err)
 	}
 
-	port := os.Getenv("ORCHESTRATOR_PORT")
+	configFile := os.Getenv(config.FileEnv)
+	source, err := config.Load(configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	port := source.Get("ORCHESTRATOR_PORT")
 	if port == "" {
 		port = "8080"
 	}
 
+	agentSecret := source.Get("AGENT_SECRET")
+	if agentSecret == "" {
+		log.Fatal("AGENT_SECRET must be set to protect internal endpoints")
+	}
+
+	current, err := loadSettings(source)
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+	current.apply(nil)
+	go watchSettings(configFile, current)
+
 	r := mux.NewRouter()
+	r.Use(logging.Middleware)
+
+	r.HandleFunc("/api/openapi.json", openapi.Handler(openapi.Orchestrator)).Methods("GET")
+	r.Handle("/api/v1/register", orchestrator.AuthRateLimit(http.HandlerFunc(auth.HandleRegister))).Methods("POST")
+	r.Handle("/api/v1/login", orchestrator.AuthRateLimit(http.HandlerFunc(auth.HandleLogin))).Methods("POST")
 
-	r.HandleFunc("/api/v1/calculate", orchestrator.HandleCalculate).Methods("POST")
-	r.HandleFunc("/api/v1/expressions", orchestrator.HandleGetExpressions).Methods("GET")
-	r.HandleFunc("/api/v1/expressions/{id}", orchestrator.HandleGetExpression).Methods("GET")
+	api := r.PathPrefix("/api/v1").Subrouter()
+	api.Use(auth.RequireUser)
+	api.Handle("/calculate", orchestrator.RateLimit(http.HandlerFunc(orchestrator.HandleCalculate))).Methods("POST")
+	api.Handle("/evaluate", orchestrator.RateLimit(http.HandlerFunc(orchestrator.HandleEvaluate))).Methods("POST")
+	api.Handle("/explain", orchestrator.RateLimit(http.HandlerFunc(orchestrator.HandleExplain))).Methods("POST")
+	api.Handle("/calculate/batch", orchestrator.RateLimit(http.HandlerFunc(orchestrator.HandleCalculateBatch))).Methods("POST")
+	api.HandleFunc("/batches/{id}", orchestrator.HandleGetBatch).Methods("GET")
+	api.HandleFunc("/expressions", orchestrator.HandleGetExpressions).Methods("GET")
+	api.HandleFunc("/expressions/{id}", orchestrator.HandleGetExpression).Methods("GET")
+	api.HandleFunc("/expressions/{id}/cancel", orchestrator.HandleCancelExpression).Methods("POST")
+	api.HandleFunc("/agents", orchestrator.HandleGetAgents).Methods("GET")
 
-	r.HandleFunc("/internal/task", orchestrator.HandleGetTask).Methods("GET")
-	r.HandleFunc("/internal/task", orchestrator.HandleSubmitTaskResult).Methods("POST")
+	internal := r.PathPrefix("/internal").Subrouter()
+	internal.Use(auth.RequireAgentSecret(agentSecret))
+	internal.HandleFunc("/task", orchestrator.HandleGetTask).Methods("GET")
+	internal.HandleFunc("/task", orchestrator.HandleSubmitTaskResult).Methods("POST")
+	internal.HandleFunc("/tasks", orchestrator.HandleGetTasks).Methods("GET")
+	internal.HandleFunc("/tasks", orchestrator.HandleSubmitTaskResults).Methods("POST")
+
+	if adminToken := source.Get("ADMIN_TOKEN"); adminToken != "" {
+		admin := r.PathPrefix("/admin").Subrouter()
+		admin.Use(auth.RequireAdminToken(adminToken))
+		admin.HandleFunc("/operation-times", orchestrator.HandleGetOperationTimes).Methods("GET")
+		admin.HandleFunc("/operation-times", orchestrator.HandleUpdateOperationTimes).Methods("PATCH")
+	} else {
+		slog.Warn("ADMIN_TOKEN is not set, admin endpoints are disabled")
+	}
+
+	r.HandleFunc("/metrics", orchestrator.HandleMetrics).Methods("GET")
 
 	webFS := http.FileServer(http.Dir("./cmd/web/static"))
 
@@ -36,9 +96,9 @@ func main() {
 
 	r.PathPrefix("/").Handler(webFS)
 
-	log.Printf("Orchestrator starting on port %s", port)
-	log.Printf("Web interface available at http://localhost:%s", port)
+	slog.Info("orchestrator starting", "port", port, "web_interface", "http://localhost:"+port)
 	if err := http.ListenAndServe(":"+port, r); err != nil {
-		log.Fatal(err)
+		slog.Error("orchestrator stopped", "error", err)
+		os.Exit(1)
 	}
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,8 +12,27 @@ import (
 	"sync"
 	"syscall"
 	"time"
+
+	"github.com/joho/godotenv"
 )
 
+// agentSecret возвращает AGENT_SECRET из окружения или .env. Если он не задан, создаёт
+// временный секрет на этот запуск, чтобы оркестратор и агент запустились с одним значением
+func agentSecret() (string, bool, error) {
+	if secret := os.Getenv("AGENT_SECRET"); secret != "" {
+		return secret, false, nil
+	}
+	if env, err := godotenv.Read(); err == nil && env["AGENT_SECRET"] != "" {
+		return env["AGENT_SECRET"], false, nil
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", false, err
+	}
+	return hex.EncodeToString(buf), true, nil
+}
+
 func waitForOrchestrator(timeout time.Duration) bool {
 	start := time.Now()
 	for {
@@ -40,12 +61,24 @@ func main() {
 
 	done := make(chan struct{})
 
+	env := os.Environ()
+	secret, generated, err := agentSecret()
+	if err != nil {
+		log.Fatalf("Ошибка создания AGENT_SECRET: %v", err)
+	}
+	if generated {
+		fmt.Println("AGENT_SECRET не задан: для этого запуска создан временный секрет.")
+		fmt.Println("Чтобы задать постоянный, укажите его в .env, например: AGENT_SECRET=$(openssl rand -hex 32)")
+		env = append(env, "AGENT_SECRET="+secret)
+	}
+
 	fmt.Println("Запуск оркестратора...")
 	orchestratorCmd := exec.Command(goCmd, "run", "./cmd/orchestrator")
+	orchestratorCmd.Env = env
 	orchestratorCmd.Stdout = os.Stdout
 	orchestratorCmd.Stderr = os.Stderr
 
-	err := orchestratorCmd.Start()
+	err = orchestratorCmd.Start()
 	if err != nil {
 		log.Fatalf("Ошибка запуска оркестратора: %v", err)
 	}
@@ -58,6 +91,7 @@ func main() {
 
 	fmt.Println("Оркестратор готов. Запуск агента...")
 	agentCmd := exec.Command(goCmd, "run", "./cmd/agent")
+	agentCmd.Env = env
 	agentCmd.Stdout = os.Stdout
 	agentCmd.Stderr = os.Stderr
 
@@ -3,8 +3,115 @@ document.addEventListener('DOMContentLoaded', () => {
     const calculateButton = document.getElementById('calculate');
     const resultDiv = document.getElementById('result');
     const historyList = document.getElementById('history-list');
+    const authSection = document.getElementById('auth');
+    const authMessage = document.getElementById('auth-message');
+    const loginInput = document.getElementById('login');
+    const passwordInput = document.getElementById('password');
+    const userBar = document.getElementById('user-bar');
+    const userLogin = document.getElementById('user-login');
+    const workspace = document.getElementById('workspace');
 
-    loadHistory();
+    document.getElementById('login-button').addEventListener('click', () => login());
+    document.getElementById('register-button').addEventListener('click', () => register());
+    document.getElementById('logout-button').addEventListener('click', () => logout());
+
+    passwordInput.addEventListener('keypress', (e) => {
+        if (e.key === 'Enter') {
+            login();
+        }
+    });
+
+    if (localStorage.getItem('apiKey')) {
+        showWorkspace();
+    } else {
+        showAuth();
+    }
+
+    function showAuth(message) {
+        authSection.hidden = false;
+        userBar.hidden = true;
+        workspace.hidden = true;
+        authMessage.innerHTML = '';
+        if (message) {
+            authMessage.appendChild(errorElement(message));
+        }
+    }
+
+    function showWorkspace() {
+        authSection.hidden = true;
+        userBar.hidden = false;
+        workspace.hidden = false;
+        userLogin.textContent = localStorage.getItem('login') || '';
+        loadHistory();
+    }
+
+    async function register() {
+        const response = await fetch('/api/v1/register', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ login: loginInput.value.trim(), password: passwordInput.value })
+        });
+
+        if (!response.ok) {
+            showAuth(await errorMessage(response));
+            return;
+        }
+
+        await login();
+    }
+
+    async function login() {
+        const response = await fetch('/api/v1/login', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ login: loginInput.value.trim(), password: passwordInput.value })
+        });
+
+        if (!response.ok) {
+            showAuth(await errorMessage(response, 'Неверный логин или пароль'));
+            return;
+        }
+
+        const data = await response.json();
+        localStorage.setItem('apiKey', data.api_key);
+        localStorage.setItem('login', loginInput.value.trim());
+        passwordInput.value = '';
+        showWorkspace();
+    }
+
+    function logout() {
+        localStorage.removeItem('apiKey');
+        localStorage.removeItem('login');
+        historyList.innerHTML = '';
+        resultDiv.innerHTML = '';
+        showAuth();
+    }
+
+    // Текст ошибки из ответа в формате problem+json (RFC 7807)
+    async function errorMessage(response, fallback) {
+        try {
+            const problem = await response.json();
+            return problem.detail || problem.title || fallback || `Ошибка: ${response.status}`;
+        } catch {
+            return fallback || `Ошибка: ${response.status} ${response.statusText}`;
+        }
+    }
+
+    // Запрос к API с ключом пользователя. При 401 возвращает на форму входа
+    async function apiFetch(url, options = {}) {
+        const headers = Object.assign({}, options.headers, {
+            'Authorization': `Bearer ${localStorage.getItem('apiKey')}`
+        });
+        const response = await fetch(url, Object.assign({}, options, { headers }));
+
+        if (response.status === 401) {
+            logout();
+            showAuth('Сессия истекла, войдите снова');
+            throw new Error('Требуется вход');
+        }
+
+        return response;
+    }
 
     calculateButton.addEventListener('click', () => {
         calculateExpression();
@@ -26,7 +133,7 @@ document.addEventListener('DOMContentLoaded', () => {
         try {
             resultDiv.innerHTML = '<div class="processing">Вычисление...</div>';
             
-            const response = await fetch('/api/v1/calculate', {
+            const response = await apiFetch('/api/v1/calculate', {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json'
@@ -35,8 +142,7 @@ document.addEventListener('DOMContentLoaded', () => {
             });
             
             if (!response.ok) {
-                const errorData = await response.json();
-                throw new Error(errorData.error || `Ошибка: ${response.status} ${response.statusText}`);
+                throw new Error(await errorMessage(response));
             }
             
             const data = await response.json();
@@ -50,10 +156,10 @@ document.addEventListener('DOMContentLoaded', () => {
 
     async function checkExpressionStatus(id) {
         try {
-            const response = await fetch(`/api/v1/expressions/${id}`);
+            const response = await apiFetch(`/api/v1/expressions/${id}`);
             
             if (!response.ok) {
-                throw new Error(`Ошибка при проверке статуса: ${response.status}`);
+                throw new Error(await errorMessage(response, 'Ошибка при проверке статуса'));
             }
             
             const data = await response.json();
@@ -68,16 +174,16 @@ document.addEventListener('DOMContentLoaded', () => {
                 setTimeout(() => checkExpressionStatus(id), 1000);
             }
         } catch (error) {
-            showError('Ошибка при получении результата');
+            showError(error.message || 'Ошибка при получении результата');
         }
     }
 
     async function loadHistory() {
         try {
-            const response = await fetch('/api/v1/expressions');
+            const response = await apiFetch('/api/v1/expressions?sort=created_at&order=desc');
             
             if (!response.ok) {
-                throw new Error(`Ошибка при загрузке истории: ${response.status}`);
+                throw new Error(await errorMessage(response, 'Ошибка при загрузке истории'));
             }
             
             const data = await response.json();
@@ -85,9 +191,7 @@ document.addEventListener('DOMContentLoaded', () => {
             historyList.innerHTML = '';
             
             if (data.expressions && data.expressions.length > 0) {
-                const sortedExpressions = [...data.expressions].reverse();
-                
-                sortedExpressions.forEach(expr => {
+                data.expressions.forEach(expr => {
                     const li = document.createElement('li');
                     li.className = `history-item ${expr.status.toLowerCase()}`;
                     
@@ -128,7 +232,16 @@ document.addEventListener('DOMContentLoaded', () => {
         }
     }
 
+    // Сообщения сервера вставляются как текст, а не как HTML
+    function errorElement(message) {
+        const div = document.createElement('div');
+        div.className = 'error';
+        div.textContent = message;
+        return div;
+    }
+
     function showError(message) {
-        resultDiv.innerHTML = `<div class="error">${message}</div>`;
+        resultDiv.innerHTML = '';
+        resultDiv.appendChild(errorElement(message));
     }
 }); 
\ No newline at end of file
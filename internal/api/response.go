@@ -1,22 +1,75 @@
 package api
 
 import (
+	"calculator-service/internal/logging"
 	"encoding/json"
 	"net/http"
 )
 
+const ProblemContentType = "application/problem+json"
+
+// Машиночитаемые коды ошибок. Клиенты ветвятся по code, а detail показывают пользователю
+const (
+	CodeInvalidBody         = "invalid_body"
+	CodeBodyTooLarge        = "body_too_large"
+	CodeInvalidParameter    = "invalid_parameter"
+	CodeInvalidExpression   = "invalid_expression"
+	CodeExpressionTooLarge  = "expression_too_large"
+	CodeInvalidCallbackURL  = "invalid_callback_url"
+	CodeInvalidBatch        = "invalid_batch"
+	CodeDuplicateKey        = "duplicate_key"
+	CodeTooManyPending      = "too_many_pending"
+	CodeRateLimited         = "rate_limited"
+	CodeIdempotencyConflict = "idempotency_conflict"
+	CodeIdempotencyInFlight = "idempotency_in_progress"
+	CodeExpressionNotFound  = "expression_not_found"
+	CodeExpressionFinished  = "expression_finished"
+	CodeBatchNotFound       = "batch_not_found"
+	CodeTaskNotFound        = "task_not_found"
+	CodeUnauthorized        = "unauthorized"
+	CodeInvalidAgentSecret  = "invalid_agent_secret"
+	CodeInvalidAdminToken   = "invalid_admin_token"
+	CodeUnsupportedProtocol = "unsupported_protocol_version"
+	CodeInvalidCredentials  = "invalid_credentials"
+	CodeInvalidLogin        = "invalid_login"
+	CodeWeakPassword        = "weak_password"
+	CodeUserExists          = "user_exists"
+	CodeRegistrationClosed  = "registration_closed"
+	CodeInternal            = "internal_error"
+)
+
+// ErrorResponse - ошибка в формате RFC 7807 (application/problem+json)
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
 type SuccessResponse struct {
 	Result float64 `json:"result"`
 }
 
-func SendErrorResponse(w http.ResponseWriter, status int, message string) {
-	w.Header().Set("Content-Type", "application/json")
+// SendErrorResponse отвечает ошибкой problem+json. type ошибки строится из code
+func SendErrorResponse(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	problem := ErrorResponse{
+		Type:   "urn:calculator:error:" + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+	if r != nil {
+		problem.Instance = r.URL.Path
+		problem.RequestID = logging.RequestID(r.Context())
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
 	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
+	json.NewEncoder(w).Encode(problem)
 }
 
 func SendSuccessResponse(w http.ResponseWriter, result float64) {
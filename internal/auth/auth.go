@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"calculator-service/internal/api"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	APIKeyHeader      = "X-API-Key"
+	AgentSecretHeader = "X-Agent-Secret"
+	AdminTokenHeader  = "X-Admin-Token"
+
+	passwordIterations = 100_000
+	minPasswordLength  = 8
+	maxLoginLength     = 64
+)
+
+var (
+	ErrUserExists         = errors.New("user already exists")
+	ErrInvalidCredentials = errors.New("invalid login or password")
+	ErrInvalidLogin       = errors.New("login must be 1-64 characters without spaces")
+	ErrWeakPassword       = errors.New("password must be at least 8 characters")
+	ErrTooManyUsers       = errors.New("registration is closed: user limit reached")
+)
+
+type User struct {
+	ID    string `json:"id"`
+	Login string `json:"login"`
+
+	salt         []byte
+	passwordHash []byte
+}
+
+var (
+	users        = make(map[string]*User)  // login -> пользователь
+	apiKeyHashes = make(map[string]string) // sha256(ключ) -> ID пользователя
+	maxUsers     int                       // 0 - без ограничения
+	mu           sync.RWMutex
+)
+
+type contextKey struct{}
+
+func ResetState() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	users = make(map[string]*User)
+	apiKeyHashes = make(map[string]string)
+}
+
+// SetMaxUsers ограничивает число зарегистрированных пользователей, 0 снимает ограничение
+func SetMaxUsers(n int) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	maxUsers = n
+}
+
+func Register(login, password string) (User, error) {
+	login = strings.TrimSpace(login)
+	if login == "" || utf8.RuneCountInString(login) > maxLoginLength || strings.ContainsAny(login, " \t\n") {
+		return User{}, ErrInvalidLogin
+	}
+	if utf8.RuneCountInString(password) < minPasswordLength {
+		return User{}, ErrWeakPassword
+	}
+
+	salt := randomBytes(16)
+	user := &User{
+		ID:           uuid.New().String(),
+		Login:        login,
+		salt:         salt,
+		passwordHash: hashPassword(password, salt),
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, exists := users[login]; exists {
+		return User{}, ErrUserExists
+	}
+	if maxUsers > 0 && len(users) >= maxUsers {
+		return User{}, ErrTooManyUsers
+	}
+	users[login] = user
+	return *user, nil
+}
+
+// Login проверяет пароль и выдаёт новый API-ключ. Хранится только хеш ключа
+func Login(login, password string) (string, error) {
+	mu.RLock()
+	user, exists := users[strings.TrimSpace(login)]
+	mu.RUnlock()
+
+	if !exists {
+		hashPassword(password, randomBytes(16)) // одинаковое время ответа для несуществующих пользователей
+		return "", ErrInvalidCredentials
+	}
+	if !hmac.Equal(hashPassword(password, user.salt), user.passwordHash) {
+		return "", ErrInvalidCredentials
+	}
+
+	apiKey := "ck_" + base64.RawURLEncoding.EncodeToString(randomBytes(32))
+
+	mu.Lock()
+	apiKeyHashes[hashAPIKey(apiKey)] = user.ID
+	mu.Unlock()
+
+	return apiKey, nil
+}
+
+func Authenticate(apiKey string) (string, bool) {
+	if apiKey == "" {
+		return "", false
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	userID, ok := apiKeyHashes[hashAPIKey(apiKey)]
+	return userID, ok
+}
+
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, userID)
+}
+
+// UserID возвращает владельца запроса, пустая строка - запрос без аутентификации
+func UserID(ctx context.Context) string {
+	userID, _ := ctx.Value(contextKey{}).(string)
+	return userID
+}
+
+// APIKeyFromRequest берёт ключ из X-API-Key или из Authorization: Bearer
+func APIKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	if authz := r.Header.Get("Authorization"); strings.HasPrefix(authz, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(authz, "Bearer "))
+	}
+	return ""
+}
+
+func RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := Authenticate(APIKeyFromRequest(r))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="calculator"`)
+			api.SendErrorResponse(w, r, http.StatusUnauthorized, api.CodeUnauthorized, "Missing or invalid API key")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithUserID(r.Context(), userID)))
+	})
+}
+
+// RequireAgentSecret защищает внутренние эндпоинты общим секретом агентов
+func RequireAgentSecret(secret string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got := r.Header.Get(AgentSecretHeader)
+			if subtle.ConstantTimeCompare([]byte(got), []byte(secret)) != 1 {
+				api.SendErrorResponse(w, r, http.StatusUnauthorized, api.CodeInvalidAgentSecret, "Invalid agent secret")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAdminToken защищает административные эндпоинты токеном ADMIN_TOKEN
+func RequireAdminToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got := r.Header.Get(AdminTokenHeader)
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				api.SendErrorResponse(w, r, http.StatusUnauthorized, api.CodeInvalidAdminToken, "Invalid admin token")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func hashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashPassword - PBKDF2-HMAC-SHA256 с одним блоком выхода
+func hashPassword(password string, salt []byte) []byte {
+	prf := hmac.New(sha256.New, []byte(password))
+
+	var counter [4]byte
+	binary.BigEndian.PutUint32(counter[:], 1)
+	prf.Write(salt)
+	prf.Write(counter[:])
+	u := prf.Sum(nil)
+
+	result := make([]byte, len(u))
+	copy(result, u)
+	for i := 1; i < passwordIterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
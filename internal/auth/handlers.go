@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"calculator-service/internal/api"
+	"calculator-service/internal/types"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// maxAuthBodyBytes - логин и пароль занимают меньше, а длинный пароль дольше хешируется
+const maxAuthBodyBytes = 4 << 10
+
+// decodeAuthRequest читает логин и пароль. При ошибке отправляет ответ клиенту и возвращает false
+func decodeAuthRequest(w http.ResponseWriter, r *http.Request, req *types.AuthRequest) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAuthBodyBytes)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			api.SendErrorResponse(w, r, http.StatusRequestEntityTooLarge, api.CodeBodyTooLarge, "Request body too large")
+			return false
+		}
+		api.SendErrorResponse(w, r, http.StatusBadRequest, api.CodeInvalidBody, "Invalid request body")
+		return false
+	}
+	return true
+}
+
+func HandleRegister(w http.ResponseWriter, r *http.Request) {
+	var req types.AuthRequest
+	if !decodeAuthRequest(w, r, &req) {
+		return
+	}
+
+	user, err := Register(req.Login, req.Password)
+	switch {
+	case errors.Is(err, ErrUserExists):
+		api.SendErrorResponse(w, r, http.StatusConflict, api.CodeUserExists, err.Error())
+		return
+	case errors.Is(err, ErrTooManyUsers):
+		slog.WarnContext(r.Context(), "registration rejected, user limit reached")
+		api.SendErrorResponse(w, r, http.StatusForbidden, api.CodeRegistrationClosed, err.Error())
+		return
+	case errors.Is(err, ErrWeakPassword):
+		api.SendErrorResponse(w, r, http.StatusUnprocessableEntity, api.CodeWeakPassword, err.Error())
+		return
+	case err != nil:
+		api.SendErrorResponse(w, r, http.StatusUnprocessableEntity, api.CodeInvalidLogin, err.Error())
+		return
+	}
+
+	slog.InfoContext(r.Context(), "user registered", "user_id", user.ID, "login", user.Login)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(user)
+}
+
+func HandleLogin(w http.ResponseWriter, r *http.Request) {
+	var req types.AuthRequest
+	if !decodeAuthRequest(w, r, &req) {
+		return
+	}
+
+	apiKey, err := Login(req.Login, req.Password)
+	if err != nil {
+		api.SendErrorResponse(w, r, http.StatusUnauthorized, api.CodeInvalidCredentials, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(types.LoginResponse{APIKey: apiKey})
+}
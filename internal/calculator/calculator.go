@@ -24,6 +24,8 @@ type Token struct {
 
 type Calculator struct {
 	tokens []Token
+	// Variables - значения переменных выражения. Без них имена в выражении считаются недопустимыми символами
+	Variables map[string]float64
 }
 
 func NewCalculator() *Calculator {
@@ -73,6 +75,17 @@ func (c *Calculator) Tokenize(expr string) error {
 			}
 			c.tokens = append(c.tokens, Token{Type: Number, Value: expr[i:j]})
 			i = j - 1
+		case c.Variables != nil && IsIdentStart(char):
+			j := i
+			for j < len(expr) && IsIdentPart(expr[j]) {
+				j++
+			}
+			value, ok := c.Variables[expr[i:j]]
+			if !ok {
+				return fmt.Errorf("unknown variable: %s", expr[i:j])
+			}
+			c.tokens = append(c.tokens, Token{Type: Number, Value: strconv.FormatFloat(value, 'f', -1, 64)})
+			i = j - 1
 		default:
 			return fmt.Errorf("invalid character: %c", char)
 		}
@@ -81,6 +94,15 @@ func (c *Calculator) Tokenize(expr string) error {
 	return nil
 }
 
+// IsIdentStart и IsIdentPart задают имена переменных: буква или _, затем буквы, цифры и _
+func IsIdentStart(char byte) bool {
+	return char == '_' || ('a' <= char && char <= 'z') || ('A' <= char && char <= 'Z')
+}
+
+func IsIdentPart(char byte) bool {
+	return IsIdentStart(char) || ('0' <= char && char <= '9')
+}
+
 func (c *Calculator) ToRPN() ([]Token, error) {
 	var output []Token
 	var stack []Token
@@ -0,0 +1,247 @@
+// Package client - HTTP-клиент публичного API оркестратора
+package client
+
+import (
+	"bytes"
+	"calculator-service/internal/api"
+	"calculator-service/internal/types"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Client struct {
+	BaseURL    string
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+func New(baseURL, apiKey string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		APIKey:     apiKey,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Error - ошибка, которую вернул сервер в формате problem+json
+type Error struct {
+	Status int
+	Code   string
+	Detail string
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Detail == "" && e.Code == "":
+		return fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status))
+	case e.Detail == "":
+		return fmt.Sprintf("%d %s (%s)", e.Status, http.StatusText(e.Status), e.Code)
+	case e.Code == "":
+		return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), e.Detail)
+	}
+	return fmt.Sprintf("%s (%s)", e.Detail, e.Code)
+}
+
+// ListOptions - фильтры и параметры страницы для List. Пустые поля не передаются
+type ListOptions struct {
+	Status      string
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	Sort        string
+	Order       string
+	Limit       int
+	Cursor      string
+}
+
+func (o ListOptions) values() url.Values {
+	v := url.Values{}
+	if o.Status != "" {
+		v.Set("status", o.Status)
+	}
+	if !o.CreatedFrom.IsZero() {
+		v.Set("created_from", o.CreatedFrom.Format(time.RFC3339))
+	}
+	if !o.CreatedTo.IsZero() {
+		v.Set("created_to", o.CreatedTo.Format(time.RFC3339))
+	}
+	if o.Sort != "" {
+		v.Set("sort", o.Sort)
+	}
+	if o.Order != "" {
+		v.Set("order", o.Order)
+	}
+	if o.Limit > 0 {
+		v.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Cursor != "" {
+		v.Set("cursor", o.Cursor)
+	}
+	return v
+}
+
+// Submit отправляет выражение. С wait > 0 сервер держит запрос до результата, но не дольше wait;
+// если выражение не успело завершиться, возвращается выражение в статусе PROCESSING только с ID
+func (c *Client) Submit(ctx context.Context, expression string, wait time.Duration) (types.Expression, error) {
+	path := "/api/v1/calculate"
+	if wait > 0 {
+		path += "?wait=" + url.QueryEscape(wait.String())
+	}
+
+	var raw json.RawMessage
+	if err := c.do(ctx, http.MethodPost, path, types.CalculateRequest{Expression: expression}, &raw); err != nil {
+		return types.Expression{}, err
+	}
+
+	expr := types.Expression{Original: expression, Status: types.StatusProcessing}
+	if err := json.Unmarshal(raw, &expr); err != nil {
+		return types.Expression{}, fmt.Errorf("decode response: %w", err)
+	}
+	return expr, nil
+}
+
+func (c *Client) SubmitBatch(ctx context.Context, expressions []string) (types.Batch, error) {
+	req := types.BatchRequest{Expressions: make([]types.BatchItemRequest, 0, len(expressions))}
+	for _, expression := range expressions {
+		req.Expressions = append(req.Expressions, types.BatchItemRequest{Expression: expression})
+	}
+
+	var batch types.Batch
+	err := c.do(ctx, http.MethodPost, "/api/v1/calculate/batch", req, &batch)
+	return batch, err
+}
+
+func (c *Client) Batch(ctx context.Context, id string) (types.Batch, error) {
+	var batch types.Batch
+	err := c.do(ctx, http.MethodGet, "/api/v1/batches/"+url.PathEscape(id), nil, &batch)
+	return batch, err
+}
+
+func (c *Client) Get(ctx context.Context, id string) (types.Expression, error) {
+	var expr types.Expression
+	err := c.do(ctx, http.MethodGet, "/api/v1/expressions/"+url.PathEscape(id), nil, &expr)
+	return expr, err
+}
+
+func (c *Client) List(ctx context.Context, opts ListOptions) (types.ExpressionResponse, error) {
+	path := "/api/v1/expressions"
+	if query := opts.values().Encode(); query != "" {
+		path += "?" + query
+	}
+
+	var list types.ExpressionResponse
+	err := c.do(ctx, http.MethodGet, path, nil, &list)
+	return list, err
+}
+
+func (c *Client) Cancel(ctx context.Context, id string) (types.Expression, error) {
+	var expr types.Expression
+	err := c.do(ctx, http.MethodPost, "/api/v1/expressions/"+url.PathEscape(id)+"/cancel", nil, &expr)
+	return expr, err
+}
+
+func (c *Client) Agents(ctx context.Context) ([]types.Agent, error) {
+	var list types.AgentList
+	err := c.do(ctx, http.MethodGet, "/api/v1/agents", nil, &list)
+	return list.Agents, err
+}
+
+// Watch опрашивает выражение раз в interval, пока оно выполняется, и вызывает progress
+// при каждом изменении статуса или числа выполненных задач. Возвращает завершённое выражение
+func (c *Client) Watch(ctx context.Context, id string, interval time.Duration, progress func(types.Expression)) (types.Expression, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastState := ""
+	for {
+		expr, err := c.Get(ctx, id)
+		if err != nil {
+			return expr, err
+		}
+
+		state := expr.Status
+		if expr.Metrics != nil {
+			state += fmt.Sprintf("/%d/%d", expr.Metrics.TasksCompleted, expr.Metrics.Tasks)
+		}
+		if state != lastState && progress != nil {
+			progress(expr)
+		}
+		lastState = state
+
+		if expr.Status != types.StatusProcessing {
+			return expr, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return expr, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return responseError(resp)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// maxErrorDetail - сколько байт тела ответа без problem+json показывается в ошибке
+const maxErrorDetail = 512
+
+// responseError разбирает ответ об ошибке. Если это не problem+json, например ответ прокси,
+// в Detail попадает начало тела ответа
+func responseError(resp *http.Response) *Error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	var problem api.ErrorResponse
+	if err := json.Unmarshal(body, &problem); err == nil && problem.Code != "" {
+		return &Error{Status: resp.StatusCode, Code: problem.Code, Detail: problem.Detail}
+	}
+
+	detail := strings.TrimSpace(string(body))
+	if len(detail) > maxErrorDetail {
+		detail = strings.ToValidUTF8(detail[:maxErrorDetail], "") + "..."
+	}
+	return &Error{Status: resp.StatusCode, Detail: detail}
+}
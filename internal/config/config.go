@@ -0,0 +1,116 @@
+// Package config собирает настройки сервисов из переменных окружения и JSON-файла CONFIG_FILE
+// и перечитывает файл без перезапуска: по SIGHUP или при его изменении
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// FileEnv - переменная окружения с путём к файлу конфигурации
+	FileEnv = "CONFIG_FILE"
+	// PollInterval - как часто Watch проверяет, не изменился ли файл
+	PollInterval = 2 * time.Second
+)
+
+// Source - настройки в виде переменных: значения из файла перекрывают переменные окружения
+type Source struct {
+	file map[string]string
+}
+
+// Load читает файл конфигурации - JSON-объект с теми же именами, что у переменных окружения:
+// {"COMPUTING_POWER": 8, "RESULT_CACHE_TTL": "5m"}. Пустой путь - только переменные окружения
+func Load(path string) (Source, error) {
+	s := Source{file: make(map[string]string)}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, fmt.Errorf("read config file: %w", err)
+	}
+
+	var values map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return s, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	var invalid []string
+	for key, value := range values {
+		switch v := value.(type) {
+		case string:
+			s.file[key] = v
+		case json.Number:
+			s.file[key] = v.String()
+		case bool:
+			s.file[key] = strconv.FormatBool(v)
+		default:
+			invalid = append(invalid, key)
+		}
+	}
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return s, fmt.Errorf("config file %s: values of %v must be strings, numbers or booleans", path, invalid)
+	}
+	return s, nil
+}
+
+// Get возвращает значение настройки или пустую строку, если она не задана
+func (s Source) Get(key string) string {
+	if value, ok := s.file[key]; ok {
+		return value
+	}
+	return os.Getenv(key)
+}
+
+// Watch вызывает reload по SIGHUP и при изменении файла path, который проверяется раз в interval.
+// Без файла перечитывание возможно только по сигналу. Работает до отмены ctx
+func Watch(ctx context.Context, path string, interval time.Duration, reload func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var ticks <-chan time.Time
+	last := fileVersion(path)
+	if path != "" {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			last = fileVersion(path)
+			reload()
+		case <-ticks:
+			if version := fileVersion(path); version != last {
+				last = version
+				reload()
+			}
+		}
+	}
+}
+
+// fileVersion - время изменения и размер файла, по ним Watch замечает изменения
+func fileVersion(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", info.ModTime().UnixNano(), info.Size())
+}
@@ -0,0 +1,242 @@
+// Package openapi хранит спецификацию OpenAPI оркестратора и проверяет ответы на соответствие ей
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed orchestrator.json
+var Orchestrator []byte
+
+// Handler отдаёт спецификацию как /api/openapi.json
+func Handler(spec []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	}
+}
+
+type Document struct {
+	root map[string]any
+}
+
+func Parse(spec []byte) (*Document, error) {
+	var root map[string]any
+	if err := json.Unmarshal(spec, &root); err != nil {
+		return nil, fmt.Errorf("parse openapi document: %w", err)
+	}
+	return &Document{root: root}, nil
+}
+
+// Operations возвращает операции документа в виде "METHOD /path"
+func (d *Document) Operations() []string {
+	var ops []string
+	paths, _ := d.root["paths"].(map[string]any)
+	for path, item := range paths {
+		methods, _ := item.(map[string]any)
+		for method := range methods {
+			ops = append(ops, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+// ValidateResponse проверяет ответ операции. path - шаблон из спецификации,
+// например /api/v1/expressions/{id}. Поля ответа, не описанные в схеме, считаются ошибкой
+func (d *Document) ValidateResponse(method, path string, status int, header http.Header, body []byte) error {
+	op, ok := lookup(d.root, "paths", path, strings.ToLower(method))
+	if !ok {
+		return fmt.Errorf("operation %s %s is not documented", method, path)
+	}
+	resp, ok := lookup(op, "responses", strconv.Itoa(status))
+	if !ok {
+		return fmt.Errorf("%s %s: status %d is not documented", method, path, status)
+	}
+	resp, err := d.resolve(resp)
+	if err != nil {
+		return err
+	}
+
+	content, _ := resp.(map[string]any)["content"].(map[string]any)
+	if len(content) == 0 {
+		if len(strings.TrimSpace(string(body))) != 0 {
+			return fmt.Errorf("%s %s %d: unexpected response body", method, path, status)
+		}
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	media, ok := content[mediaType].(map[string]any)
+	if !ok {
+		return fmt.Errorf("%s %s %d: content type %q is not documented", method, path, status, mediaType)
+	}
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return nil
+	}
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("%s %s %d: invalid JSON: %w", method, path, status, err)
+	}
+	if err := d.validate(media["schema"], value, "body"); err != nil {
+		return fmt.Errorf("%s %s %d: %w", method, path, status, err)
+	}
+	return nil
+}
+
+func (d *Document) resolve(node any) (any, error) {
+	for i := 0; i < 10; i++ {
+		m, ok := node.(map[string]any)
+		if !ok {
+			return node, nil
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return node, nil
+		}
+
+		parts := strings.Split(strings.TrimPrefix(ref, "#/"), "/")
+		target, found := lookup(d.root, parts...)
+		if !found {
+			return nil, fmt.Errorf("unresolved $ref %s", ref)
+		}
+		node = target
+	}
+	return nil, fmt.Errorf("$ref chain is too deep")
+}
+
+// validate поддерживает подмножество JSON Schema, которое используется в спецификациях:
+// type, nullable, enum, format date-time, required, properties, additionalProperties, items и oneOf
+func (d *Document) validate(schemaNode, value any, at string) error {
+	resolved, err := d.resolve(schemaNode)
+	if err != nil {
+		return err
+	}
+	schema, ok := resolved.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); nullable {
+			return nil
+		}
+		return fmt.Errorf("%s: null is not allowed", at)
+	}
+
+	if variants, ok := schema["oneOf"].([]any); ok {
+		matched := 0
+		for _, variant := range variants {
+			if d.validate(variant, value, at) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return fmt.Errorf("%s: matches %d of oneOf schemas, expected exactly 1", at, matched)
+		}
+		return nil
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, allowed := range enum {
+			if allowed == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", at, value, enum)
+		}
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %T", at, value)
+		}
+		return d.validateObject(schema, obj, at)
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %T", at, value)
+		}
+		for i, item := range arr {
+			if err := d.validate(schema["items"], item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected string, got %T", at, value)
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				return fmt.Errorf("%s: %q is not a date-time", at, s)
+			}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: expected number, got %T", at, value)
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s: expected integer, got %v", at, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean, got %T", at, value)
+		}
+	}
+	return nil
+}
+
+func (d *Document) validateObject(schema, obj map[string]any, at string) error {
+	required, _ := schema["required"].([]any)
+	for _, name := range required {
+		if _, ok := obj[name.(string)]; !ok {
+			return fmt.Errorf("%s: missing required property %q", at, name)
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+	additional, _ := schema["additionalProperties"].(bool)
+	for name, value := range obj {
+		propSchema, ok := properties[name]
+		if !ok {
+			if additional {
+				continue
+			}
+			return fmt.Errorf("%s: property %q is not documented", at, name)
+		}
+		if err := d.validate(propSchema, value, at+"."+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func lookup(node any, keys ...string) (any, bool) {
+	for _, key := range keys {
+		m, ok := node.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if node, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return node, true
+}
@@ -0,0 +1,175 @@
+package orchestrator
+
+import (
+	"calculator-service/internal/types"
+	"encoding/json"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// agentOfflineAfter - агент, не запрашивавший задачи дольше этого времени, считается отключившимся
+const agentOfflineAfter = time.Minute
+
+type agentState struct {
+	id              string
+	protocolVersion int
+	capacity        int
+	capabilities    []string
+	firstSeen       time.Time
+	lastSeen        time.Time
+	completed       int
+	failed          int
+}
+
+// agentID определяет агента по X-Agent-ID, а для агентов без заголовка - по адресу
+func agentID(r *http.Request) string {
+	if id := r.Header.Get(types.AgentIDHeader); id != "" {
+		return id
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// Функции ниже вызываются под mu.Lock()
+
+func trackAgent(r *http.Request, version int, now time.Time) string {
+	id := agentID(r)
+	agent, ok := agents[id]
+	if !ok {
+		agent = &agentState{id: id, firstSeen: now}
+		agents[id] = agent
+	}
+	agent.protocolVersion = version
+	if capacity, err := strconv.Atoi(r.Header.Get(types.AgentCapacityHeader)); err == nil && capacity > 0 {
+		agent.capacity = capacity
+	}
+	// Возможности агент сообщает при запросе задач. В запросах с результатами заголовка нет,
+	// и они не меняют возможности. Агент без заголовка выполняет все базовые операции
+	if capabilities := types.ParseCapabilities(r.Header.Get(types.AgentCapabilitiesHeader)); len(capabilities) > 0 {
+		agent.capabilities = capabilities
+	} else if r.Method == http.MethodGet || agent.capabilities == nil {
+		agent.capabilities = types.BasicOperations
+	}
+	agent.lastSeen = now
+	return id
+}
+
+func (a *agentState) online(now time.Time) bool {
+	return now.Sub(a.lastSeen) <= agentOfflineAfter
+}
+
+// canRun - умеет ли агент выполнять операцию задачи
+func (a *agentState) canRun(task types.Task) bool {
+	return slices.Contains(a.capabilities, task.Operation)
+}
+
+// unschedulable - задачу не может выполнить ни один подключённый агент. Пока агентов нет,
+// задачи просто ждут их и невыполнимыми не считаются
+func unschedulable(task types.Task, now time.Time) bool {
+	connected := false
+	for _, agent := range agents {
+		if !agent.online(now) {
+			continue
+		}
+		if agent.canRun(task) {
+			return false
+		}
+		connected = true
+	}
+	return connected
+}
+
+// unschedulableOperations - операции невыданных задач, которые не может выполнить ни один подключённый агент
+func unschedulableOperations(taskIDs []string, now time.Time) []string {
+	var operations []string
+	for _, id := range taskIDs {
+		task, ok := tasks[id]
+		if ok && !slices.Contains(operations, task.Operation) && unschedulable(task, now) {
+			operations = append(operations, task.Operation)
+		}
+	}
+	sort.Strings(operations)
+	return operations
+}
+
+// recordAgentResult учитывает результат задачи у агента, которому она была выдана
+func recordAgentResult(taskID string, failed bool) {
+	id, ok := taskAgents[taskID]
+	if !ok {
+		return
+	}
+	delete(taskAgents, taskID)
+
+	if agent, ok := agents[id]; ok {
+		if failed {
+			agent.failed++
+		} else {
+			agent.completed++
+		}
+	}
+}
+
+// onlineAgents - копии подключённых агентов для расчётов после mu.Unlock(). Вызывается под mu
+func onlineAgents(now time.Time) []agentState {
+	var online []agentState
+	for _, agent := range agents {
+		if agent.online(now) {
+			online = append(online, *agent)
+		}
+	}
+	return online
+}
+
+// totalCapacity - сколько задач агенты могут выполнять одновременно.
+// Агент, не сообщивший ёмкость, считается выполняющим одну задачу
+func totalCapacity(agents []agentState) int {
+	workers := 0
+	for _, agent := range agents {
+		workers += max(agent.capacity, 1)
+	}
+	return workers
+}
+
+func HandleGetAgents(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+
+	mu.RLock()
+	inProgress := make(map[string]int)
+	for _, id := range taskAgents {
+		inProgress[id]++
+	}
+	list := types.AgentList{Agents: make([]types.Agent, 0, len(agents))}
+	for _, agent := range agents {
+		status := types.AgentOnline
+		if !agent.online(now) {
+			status = types.AgentOffline
+		}
+		list.Agents = append(list.Agents, types.Agent{
+			ID:              agent.id,
+			Status:          status,
+			ProtocolVersion: agent.protocolVersion,
+			Capacity:        agent.capacity,
+			Capabilities:    agent.capabilities,
+			FirstSeenAt:     agent.firstSeen,
+			LastSeenAt:      agent.lastSeen,
+			TasksInProgress: inProgress[agent.id],
+			TasksCompleted:  agent.completed,
+			TasksFailed:     agent.failed,
+		})
+	}
+	mu.RUnlock()
+
+	sort.Slice(list.Agents, func(i, j int) bool {
+		return list.Agents[i].ID < list.Agents[j].ID
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
@@ -0,0 +1,167 @@
+package orchestrator
+
+import (
+	"calculator-service/internal/api"
+	"calculator-service/internal/auth"
+	"calculator-service/internal/types"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type batch struct {
+	id        string
+	ownerID   string
+	createdAt time.Time
+	items     []types.BatchItem
+}
+
+var batchesLastSweep time.Time
+
+func HandleCalculateBatch(w http.ResponseWriter, r *http.Request) {
+	limits := getLimits()
+	var req types.BatchRequest
+	if !decodeRequest(w, r, limits.MaxBatchBodyBytes, &req) {
+		return
+	}
+
+	if len(req.Expressions) == 0 {
+		api.SendErrorResponse(w, r, http.StatusUnprocessableEntity, api.CodeInvalidBatch, "Batch must contain at least one expression")
+		return
+	}
+	if limits.MaxBatchSize > 0 && len(req.Expressions) > limits.MaxBatchSize {
+		api.SendErrorResponse(w, r, http.StatusUnprocessableEntity, api.CodeInvalidBatch, fmt.Sprintf("Batch must contain at most %d expressions", limits.MaxBatchSize))
+		return
+	}
+
+	b := &batch{
+		id:        uuid.New().String(),
+		ownerID:   auth.UserID(r.Context()),
+		createdAt: time.Now(),
+		items:     make([]types.BatchItem, 0, len(req.Expressions)),
+	}
+
+	seenKeys := make(map[string]bool)
+	for _, itemReq := range req.Expressions {
+		item := types.BatchItem{Key: itemReq.Key, Expression: itemReq.Expression}
+
+		if itemReq.Key != "" && seenKeys[itemReq.Key] {
+			item.Error = "Duplicate key in batch"
+			item.ErrorCode = api.CodeDuplicateKey
+			b.items = append(b.items, item)
+			continue
+		}
+		seenKeys[itemReq.Key] = true
+
+		expr, submitErr := submitExpression(r, types.CalculateRequest{Expression: itemReq.Expression})
+		if submitErr != nil {
+			item.Error = submitErr.message
+			item.ErrorCode = submitErr.code
+		} else {
+			item.ID = expr.ID
+		}
+		b.items = append(b.items, item)
+	}
+
+	mu.Lock()
+	sweepBatches(limits.BatchTTL, b.createdAt)
+	batches[b.id] = b
+	view := batchView(b)
+	mu.Unlock()
+
+	slog.InfoContext(r.Context(), "batch accepted", "batch_id", b.id, "total", view.Total, "rejected", view.Rejected)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(view)
+}
+
+func HandleGetBatch(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	mu.RLock()
+	b, exists := batches[id]
+	exists = exists && b.ownerID == auth.UserID(r.Context()) && !batchExpired(b, getLimits().BatchTTL, time.Now())
+	var view types.Batch
+	if exists {
+		view = batchView(b)
+	}
+	mu.RUnlock()
+
+	if !exists {
+		api.SendErrorResponse(w, r, http.StatusNotFound, api.CodeBatchNotFound, "Batch not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(view)
+}
+
+// batchExpired - пакет старше BATCH_TTL, в котором не осталось выражений в обработке.
+// Незавершённый пакет хранится, пока клиент может ждать его результатов. Вызывается под mu
+func batchExpired(b *batch, ttl time.Duration, now time.Time) bool {
+	if ttl <= 0 || now.Sub(b.createdAt) <= ttl {
+		return false
+	}
+	for _, item := range b.items {
+		if expr, ok := expressions[item.ID]; ok && expr.Status == types.StatusProcessing {
+			return false
+		}
+	}
+	return true
+}
+
+// sweepBatches раз в минуту удаляет истёкшие пакеты. Вызывается под mu.Lock()
+func sweepBatches(ttl time.Duration, now time.Time) {
+	if now.Sub(batchesLastSweep) <= time.Minute {
+		return
+	}
+	for id, b := range batches {
+		if batchExpired(b, ttl, now) {
+			delete(batches, id)
+		}
+	}
+	batchesLastSweep = now
+}
+
+// batchView собирает текущее состояние пакета по его выражениям. Вызывается под mu
+func batchView(b *batch) types.Batch {
+	view := types.Batch{
+		ID:        b.id,
+		CreatedAt: b.createdAt,
+		Total:     len(b.items),
+		Items:     make([]types.BatchItem, 0, len(b.items)),
+	}
+
+	for _, item := range b.items {
+		if item.ID == "" {
+			view.Rejected++
+			view.Items = append(view.Items, item)
+			continue
+		}
+
+		expr := expressions[item.ID]
+		item.Status = expr.Status
+		switch expr.Status {
+		case types.StatusCompleted:
+			result := expr.Result
+			item.Result = &result
+			view.Completed++
+		case types.StatusError:
+			view.Failed++
+		default:
+			view.Processing++
+		}
+		view.Items = append(view.Items, item)
+	}
+
+	view.Status = types.StatusCompleted
+	if view.Processing > 0 {
+		view.Status = types.StatusProcessing
+	}
+	return view
+}
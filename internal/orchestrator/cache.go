@@ -0,0 +1,145 @@
+package orchestrator
+
+import (
+	"calculator-service/internal/types"
+	"container/list"
+	"net/http"
+	"time"
+)
+
+const (
+	cacheHit    = "hit"
+	cacheShared = "shared"
+	cacheMiss   = "miss"
+)
+
+type cacheEntry struct {
+	key      string
+	result   float64
+	storedAt time.Time
+}
+
+// resultCache - LRU-кэш результатов по нормализованному выражению. Вызывается под mu.Lock()
+type resultCache struct {
+	entries map[string]*list.Element
+	order   *list.List // в начале - недавно использованные
+}
+
+func newResultCache() *resultCache {
+	return &resultCache{entries: make(map[string]*list.Element), order: list.New()}
+}
+
+func (c *resultCache) get(key string, ttl time.Duration, now time.Time) (float64, bool) {
+	el, ok := c.entries[key]
+	if !ok {
+		return 0, false
+	}
+
+	entry := el.Value.(*cacheEntry)
+	if now.Sub(entry.storedAt) > ttl {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return 0, false
+	}
+
+	c.order.MoveToFront(el)
+	return entry.result, true
+}
+
+func (c *resultCache) put(key string, result float64, size int, now time.Time) {
+	if el, ok := c.entries[key]; ok {
+		el.Value = &cacheEntry{key: key, result: result, storedAt: now}
+		c.order.MoveToFront(el)
+	} else {
+		c.entries[key] = c.order.PushFront(&cacheEntry{key: key, result: result, storedAt: now})
+	}
+
+	for c.order.Len() > size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *resultCache) len() int {
+	return c.order.Len()
+}
+
+// Функции ниже вызываются под mu.Lock()
+
+// reuseResult завершает выражение результатом из кэша или присоединяет его
+// к такому же выполняющемуся выражению, не создавая новых задач
+func reuseResult(r *http.Request, expr types.Expression, key string) (types.Expression, bool) {
+	limits := getLimits()
+	if limits.CacheSize > 0 && limits.CacheTTL > 0 {
+		if result, ok := results.get(key, limits.CacheTTL, time.Now()); ok {
+			cacheLookups.Inc(cacheHit)
+
+			completedAt := expr.CreatedAt
+			expr.Status = types.StatusCompleted
+			expr.Result = result
+			expr.CompletedAt = &completedAt
+			expr.Metrics = &types.ExpressionMetrics{}
+			expr.Cached = true
+			expressions[expr.ID] = expr
+			expressionSpans[expr.ID] = startExpressionSpan(r, expr)
+			expressionSpans[expr.ID].SetAttr("cache", cacheHit)
+			finishExpressionSpan(expr)
+			scheduleWebhook(expr)
+			return expr, true
+		}
+	}
+
+	if leaderID, ok := inflightExpressions[key]; ok {
+		cacheLookups.Inc(cacheShared)
+
+		expr.Cached = true
+		expressions[expr.ID] = expr
+		expressionSpans[expr.ID] = startExpressionSpan(r, expr)
+		expressionSpans[expr.ID].SetAttr("cache", cacheShared)
+		expressionDone[expr.ID] = make(chan struct{})
+		expressionFollowers[leaderID] = append(expressionFollowers[leaderID], expr.ID)
+		return expr, true
+	}
+
+	cacheLookups.Inc(cacheMiss)
+	return expr, false
+}
+
+// trackInflight запоминает выражение, к которому присоединяются такие же отправки
+func trackInflight(exprID, key string) {
+	inflightExpressions[key] = exprID
+	expressionCacheKeys[exprID] = key
+}
+
+// completeShared кэширует результат выражения и завершает присоединённые к нему выражения
+func completeShared(leader types.Expression) {
+	key, ok := expressionCacheKeys[leader.ID]
+	if !ok {
+		return
+	}
+	delete(expressionCacheKeys, leader.ID)
+	if inflightExpressions[key] == leader.ID {
+		delete(inflightExpressions, key)
+	}
+
+	if limits := getLimits(); leader.Status == types.StatusCompleted && limits.CacheSize > 0 && limits.CacheTTL > 0 {
+		results.put(key, leader.Result, limits.CacheSize, *leader.CompletedAt)
+	}
+
+	for _, id := range expressionFollowers[leader.ID] {
+		expr := expressions[id]
+		completedAt := *leader.CompletedAt
+		expr.Status = leader.Status
+		expr.Result = leader.Result
+		expr.Error = leader.Error
+		expr.CompletedAt = &completedAt
+		expr.Metrics = &types.ExpressionMetrics{WallTimeMs: completedAt.Sub(expr.CreatedAt).Milliseconds()}
+		expressions[id] = expr
+
+		finishExpressionSpan(expr)
+		notifyExpressionDone(id)
+		scheduleWebhook(expr)
+	}
+	delete(expressionFollowers, leader.ID)
+}
@@ -0,0 +1,109 @@
+package orchestrator
+
+import (
+	"calculator-service/internal/api"
+	"calculator-service/internal/auth"
+	"calculator-service/internal/logging"
+	"calculator-service/internal/types"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// cancelledReason - значение поля error у отменённого выражения
+const cancelledReason = "cancelled"
+
+// HandleCancelExpression отменяет выполняющееся выражение владельца. Отменённое выражение
+// получает статус ERROR, его задачи, не нужные другим выражениям, убираются из очереди
+func HandleCancelExpression(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expr, exists := expressions[id]
+	if !exists || expr.OwnerID != auth.UserID(r.Context()) {
+		api.SendErrorResponse(w, r, http.StatusNotFound, api.CodeExpressionNotFound, "Expression not found")
+		return
+	}
+	if expr.Status != types.StatusProcessing {
+		api.SendErrorResponse(w, r, http.StatusConflict, api.CodeExpressionFinished, "Expression is already "+expr.Status)
+		return
+	}
+
+	cancelExpression(logging.With(r.Context(), "expression_id", id), id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(expressions[id])
+}
+
+// cancelExpression завершает выражение с ошибкой cancelled. Вызывается под mu.Lock()
+func cancelExpression(ctx context.Context, exprID string) {
+	// Присоединённые такие же выражения по-прежнему ждут результат, поэтому задачи переходят к первому из них
+	if followers := expressionFollowers[exprID]; len(followers) > 0 {
+		handOver(exprID, followers[0], followers[1:])
+	}
+
+	if _, ok := expressionTasks[exprID]; ok {
+		failExpression(ctx, exprID, cancelledReason)
+		return
+	}
+
+	// Своих задач у выражения нет: оно ждало такое же выполняющееся или уже передало задачи
+	for leaderID, followers := range expressionFollowers {
+		for i, id := range followers {
+			if id == exprID {
+				expressionFollowers[leaderID] = append(followers[:i:i], followers[i+1:]...)
+				break
+			}
+		}
+	}
+
+	expr := expressions[exprID]
+	completedAt := time.Now()
+	expr.Status = types.StatusError
+	expr.Error = cancelledReason
+	expr.CompletedAt = &completedAt
+	expr.Metrics = &types.ExpressionMetrics{WallTimeMs: completedAt.Sub(expr.CreatedAt).Milliseconds()}
+	expressions[exprID] = expr
+	slog.InfoContext(ctx, "expression cancelled")
+
+	finishExpressionSpan(expr)
+	releasePending(exprID)
+	notifyExpressionDone(exprID)
+	scheduleWebhook(expr)
+}
+
+// handOver передаёт задачи выражения присоединённому к нему выражению to. Вызывается под mu.Lock()
+func handOver(from, to string, rest []string) {
+	expressionTasks[to] = expressionTasks[from]
+	delete(expressionTasks, from)
+	reusedOperations[to] = reusedOperations[from]
+	delete(reusedOperations, from)
+
+	for taskID, owner := range taskToExpression {
+		if owner == from {
+			taskToExpression[taskID] = to
+		}
+	}
+	for _, waiters := range taskWaiters {
+		for i, id := range waiters {
+			if id == from {
+				waiters[i] = to
+			}
+		}
+	}
+
+	if key, ok := expressionCacheKeys[from]; ok {
+		delete(expressionCacheKeys, from)
+		trackInflight(to, key)
+	}
+	delete(expressionFollowers, from)
+	if len(rest) > 0 {
+		expressionFollowers[to] = rest
+	}
+}
@@ -0,0 +1,32 @@
+package orchestrator
+
+import (
+	"calculator-service/internal/api"
+	"calculator-service/internal/logging"
+	"calculator-service/internal/types"
+	"log/slog"
+	"net/http"
+)
+
+// HandleEvaluate вычисляет выражение сразу в оркестраторе, без разбиения на задачи для агентов.
+// Проверки и ошибки те же, что у /calculate, но выражение не сохраняется и не попадает в историю
+func HandleEvaluate(w http.ResponseWriter, r *http.Request) {
+	var req types.CalculateRequest
+	if !decodeRequest(w, r, getLimits().MaxBodyBytes, &req) {
+		return
+	}
+	if req.CallbackURL != "" {
+		api.SendErrorResponse(w, r, http.StatusUnprocessableEntity, api.CodeInvalidCallbackURL, "callback_url is not supported for synchronous evaluation")
+		return
+	}
+
+	ctx := logging.With(r.Context(), "endpoint", "evaluate")
+	_, result, submitErr := parseExpression(ctx, req.Expression)
+	if submitErr != nil {
+		api.SendErrorResponse(w, r, submitErr.status, submitErr.code, submitErr.message)
+		return
+	}
+
+	slog.DebugContext(ctx, "expression evaluated", "expression", req.Expression, "result", result)
+	api.SendSuccessResponse(w, result)
+}
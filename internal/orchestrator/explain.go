@@ -0,0 +1,346 @@
+package orchestrator
+
+import (
+	"calculator-service/internal/api"
+	"calculator-service/internal/logging"
+	"calculator-service/internal/types"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HandleExplain показывает, на какие задачи оркестратор разбил бы выражение, и оценивает время
+// его вычисления по TIME_*_MS и ёмкости подключённых агентов. Задачи не создаются.
+// С ?format=dot план отдаётся в формате Graphviz
+func HandleExplain(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "dot" {
+		api.SendErrorResponse(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "Invalid format: must be json or dot")
+		return
+	}
+
+	var req types.CalculateRequest
+	if !decodeRequest(w, r, getLimits().MaxBodyBytes, &req) {
+		return
+	}
+	if req.CallbackURL != "" {
+		api.SendErrorResponse(w, r, http.StatusUnprocessableEntity, api.CodeInvalidCallbackURL, "callback_url is not supported for explain")
+		return
+	}
+
+	ctx := logging.With(r.Context(), "endpoint", "explain")
+	root, _, submitErr := parseExpression(ctx, req.Expression)
+	if submitErr != nil {
+		api.SendErrorResponse(w, r, submitErr.status, submitErr.code, submitErr.message)
+		return
+	}
+
+	times := getOperationTimes()
+	now := time.Now()
+
+	// Планирование смотрит в кэш и выполняющиеся задачи, а чтение кэша обновляет его порядок
+	mu.Lock()
+	e := &explainer{times: times, ids: make(map[string]string)}
+	e.plan(root)
+	online := onlineAgents(now)
+	mu.Unlock()
+
+	plan := e.result(req.Expression, online)
+
+	if format == "dot" {
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		fmt.Fprint(w, planDOT(plan))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
+}
+
+// explainer повторяет обход taskPlanner, не изменяя состояние оркестратора. Вызывается под mu.Lock()
+type explainer struct {
+	times  OperationTimes
+	tasks  []types.PlanTask
+	ids    map[string]string // ключ поддерева -> задача плана
+	reused int
+}
+
+type explainArg struct {
+	value  float64
+	taskID string
+}
+
+// fields - аргумент задачи плана: число или задача, результат которой в него подставится
+func (a explainArg) fields() (*float64, string) {
+	if a.taskID != "" {
+		return nil, a.taskID
+	}
+	value := a.value
+	return &value, ""
+}
+
+func (e *explainer) plan(node *planNode) explainArg {
+	if node.isNum {
+		return explainArg{value: node.value}
+	}
+
+	if result, ok := cachedSubexpression(node.key); ok {
+		e.reused += node.operations
+		return explainArg{value: result}
+	}
+
+	if id, ok := e.ids[node.key]; ok {
+		e.reused += node.operations
+		return explainArg{taskID: id}
+	}
+
+	if _, ok := subtreeTasks[node.key]; ok {
+		id := "t" + strconv.Itoa(len(e.tasks)+1)
+		// Аргументы выполняющейся задачи здесь не важны: выражение только дождётся её результата
+		e.reused += node.operations
+		e.ids[node.key] = id
+		e.tasks = append(e.tasks, types.PlanTask{
+			ID:         id,
+			Operation:  node.op,
+			Stage:      1,
+			Source:     types.PlanTaskInFlight,
+			DurationMs: e.times.For(node.op).Milliseconds(),
+		})
+		return explainArg{taskID: id}
+	}
+
+	left := e.plan(node.left)
+	right := e.plan(node.right)
+
+	task := types.PlanTask{
+		Operation:  node.op,
+		Stage:      1,
+		Source:     types.PlanTaskNew,
+		DurationMs: e.times.For(node.op).Milliseconds(),
+	}
+	task.Arg1, task.Arg1Task = left.fields()
+	task.Arg2, task.Arg2Task = right.fields()
+	for _, dep := range planDeps(task) {
+		task.Stage = max(task.Stage, e.tasks[dep].Stage+1)
+	}
+
+	// Номер выдаётся после операндов, чтобы задачи шли в порядке создания
+	task.ID = "t" + strconv.Itoa(len(e.tasks)+1)
+	e.ids[node.key] = task.ID
+	e.tasks = append(e.tasks, task)
+	return explainArg{taskID: task.ID}
+}
+
+func (e *explainer) result(expression string, online []agentState) types.Plan {
+	plan := types.Plan{
+		Expression:       expression,
+		Tasks:            e.tasks,
+		ReusedOperations: e.reused,
+		Workers:          totalCapacity(online),
+	}
+	if plan.Tasks == nil {
+		plan.Tasks = []types.PlanTask{}
+	}
+	for _, task := range plan.Tasks {
+		plan.Depth = max(plan.Depth, task.Stage)
+	}
+
+	plan.CriticalPathMs = markCriticalPath(plan.Tasks)
+	if estimated, ok := estimateSchedule(plan.Tasks, online); ok {
+		plan.EstimatedMs = &estimated
+	}
+	return plan
+}
+
+// planIndex - номер задачи плана в срезе по её идентификатору t1, t2, ...
+func planIndex(id string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(id, "t"))
+	return n - 1
+}
+
+func planDeps(task types.PlanTask) []int {
+	var deps []int
+	for _, id := range []string{task.Arg1Task, task.Arg2Task} {
+		if id != "" {
+			deps = append(deps, planIndex(id))
+		}
+	}
+	return deps
+}
+
+// markCriticalPath считает время вычисления при неограниченном числе агентов
+// и отмечает задачи самой длинной цепочки. Задачи идут в порядке создания: зависимости раньше
+func markCriticalPath(tasks []types.PlanTask) int64 {
+	if len(tasks) == 0 {
+		return 0
+	}
+
+	finish := make([]int64, len(tasks))
+	last := 0
+	for i, task := range tasks {
+		var start int64
+		for _, dep := range planDeps(task) {
+			start = max(start, finish[dep])
+		}
+		finish[i] = start + task.DurationMs
+		if finish[i] >= finish[last] {
+			last = i
+		}
+	}
+
+	for i := last; ; {
+		tasks[i].Critical = true
+		start := finish[i] - tasks[i].DurationMs
+		next := -1
+		for _, dep := range planDeps(tasks[i]) {
+			if finish[dep] == start {
+				next = dep
+				break
+			}
+		}
+		if next < 0 {
+			break
+		}
+		i = next
+	}
+	return finish[last]
+}
+
+// estimateSchedule моделирует выдачу задач агентам: свободный агент получает готовую задачу
+// с наибольшим приоритетом из тех, что он может выполнить, как в HandleGetTask. Выполняющиеся
+// задачи других выражений не занимают агентов этого выражения и считаются завершёнными через
+// время своей операции. false - агентов нет или часть задач не может выполнить ни один из них
+func estimateSchedule(tasks []types.PlanTask, agents []agentState) (int64, bool) {
+	if len(agents) == 0 {
+		return 0, false
+	}
+	finish := make([]int64, len(tasks))
+	scheduled := make([]bool, len(tasks))
+	pending := 0
+	var end int64
+	for i, task := range tasks {
+		if task.Source == types.PlanTaskInFlight {
+			finish[i], scheduled[i] = task.DurationMs, true
+			end = max(end, finish[i])
+			continue
+		}
+		pending++
+	}
+
+	// free[j] - когда освобождается каждое место агента j, runs[i][j] - может ли он выполнить задачу i
+	free := make([][]int64, len(agents))
+	for j, agent := range agents {
+		free[j] = make([]int64, max(agent.capacity, 1))
+	}
+	runs := make([][]bool, len(tasks))
+	for i, task := range tasks {
+		runs[i] = make([]bool, len(agents))
+		for j := range agents {
+			runs[i][j] = agents[j].canRun(types.Task{Operation: task.Operation})
+		}
+	}
+
+	slots := make([]int, len(agents))
+	for ; pending > 0; pending-- {
+		// Ближайшее свободное место каждого агента
+		for j := range free {
+			slots[j] = 0
+			for k := range free[j] {
+				if free[j][k] < free[j][slots[j]] {
+					slots[j] = k
+				}
+			}
+		}
+
+		best, bestAgent, bestStart := -1, -1, int64(0)
+		for i, task := range tasks {
+			if scheduled[i] {
+				continue
+			}
+			var readyAt int64
+			ready := true
+			for _, dep := range planDeps(task) {
+				ready = ready && scheduled[dep]
+				readyAt = max(readyAt, finish[dep])
+			}
+			if !ready {
+				continue
+			}
+			for j := range agents {
+				if !runs[i][j] {
+					continue
+				}
+				start := max(readyAt, free[j][slots[j]])
+				if best < 0 || start < bestStart ||
+					start == bestStart && planPriority(task) > planPriority(tasks[best]) {
+					best, bestAgent, bestStart = i, j, start
+				}
+			}
+		}
+		if best < 0 {
+			return 0, false
+		}
+
+		scheduled[best] = true
+		finish[best] = bestStart + tasks[best].DurationMs
+		free[bestAgent][slots[bestAgent]] = finish[best]
+		end = max(end, finish[best])
+	}
+	return end, true
+}
+
+func planPriority(task types.PlanTask) int {
+	if task.Operation == "*" || task.Operation == "/" {
+		return 2
+	}
+	return 1
+}
+
+// planDOT рисует план в формате Graphviz: стрелка ведёт от задачи к задаче, которая ждёт её результат.
+// Задачи критического пути выделены, выполняющиеся задачи других выражений - пунктиром
+func planDOT(plan types.Plan) string {
+	var b strings.Builder
+	b.WriteString("digraph plan {\n")
+	b.WriteString("\trankdir=BT;\n")
+	b.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+
+	for _, task := range plan.Tasks {
+		label := task.Operation
+		if task.Source == types.PlanTaskInFlight {
+			label += "\\nin flight"
+		} else {
+			label += "\\n" + dotArg(task.Arg1, task.Arg1Task) + ", " + dotArg(task.Arg2, task.Arg2Task)
+		}
+		label += fmt.Sprintf("\\nstage %d, %d ms", task.Stage, task.DurationMs)
+
+		var attrs []string
+		attrs = append(attrs, fmt.Sprintf("label=\"%s: %s\"", task.ID, label))
+		if task.Critical {
+			attrs = append(attrs, "color=red", "penwidth=2")
+		}
+		if task.Source == types.PlanTaskInFlight {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(&b, "\t%s [%s];\n", task.ID, strings.Join(attrs, ", "))
+	}
+
+	for _, task := range plan.Tasks {
+		for _, dep := range []string{task.Arg1Task, task.Arg2Task} {
+			if dep != "" {
+				fmt.Fprintf(&b, "\t%s -> %s;\n", dep, task.ID)
+			}
+		}
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func dotArg(value *float64, taskID string) string {
+	if taskID != "" {
+		return taskID
+	}
+	return strconv.FormatFloat(*value, 'g', -1, 64)
+}
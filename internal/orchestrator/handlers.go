@@ -1,29 +1,54 @@
 package orchestrator
 
 import (
+	"calculator-service/internal/api"
+	"calculator-service/internal/auth"
 	"calculator-service/internal/calculator"
+	"calculator-service/internal/logging"
+	"calculator-service/internal/tracing"
 	"calculator-service/internal/types"
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
-	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
 var (
-	expressions      = make(map[string]types.Expression)
-	tasks            = make(map[string]types.Task)
-	taskResults      = make(map[string]float64)
-	taskToExpression = make(map[string]string)
-	expressionTasks  = make(map[string][]string)
-	dependsOnTask    = make(map[string]string) // Карта зависимостей: taskID -> taskID, от которого зависит
-	mu               sync.RWMutex
-	calc             = calculator.NewCalculator()
+	expressions         = make(map[string]types.Expression)
+	tasks               = make(map[string]types.Task)
+	taskResults         = make(map[string]float64)
+	taskToExpression    = make(map[string]string)
+	expressionTasks     = make(map[string][]string)
+	dependsOnTask       = make(map[string]taskDeps) // Карта зависимостей: taskID -> задачи, от которых зависит
+	taskTimings         = make(map[string]*taskTiming)
+	expressionSpans     = make(map[string]*tracing.Span)
+	pendingByClient     = make(map[string]int)
+	expressionClients   = make(map[string]string)
+	batches             = make(map[string]*batch)
+	expressionDone      = make(map[string]chan struct{})
+	idempotencyKeys     = make(map[string]*idempotencyRecord)
+	results             = newResultCache()
+	inflightExpressions = make(map[string]string)   // нормализованное выражение -> ID выполняющегося выражения
+	expressionCacheKeys = make(map[string]string)   // ID выражения -> нормализованное выражение
+	expressionFollowers = make(map[string][]string) // ID выражения -> присоединённые к нему выражения
+	subtreeTasks        = make(map[string]string)   // ключ поддерева -> задача, которая его вычисляет
+	taskKeys            = make(map[string]string)   // taskID -> ключ поддерева
+	taskRefs            = make(map[string]int)      // taskID -> число выражений, которым нужен результат
+	taskWaiters         = make(map[string][]string) // taskID -> выражения, переиспользующие задачу
+	reusedOperations    = make(map[string]int)      // ID выражения -> операций, взятых из других выражений
+	agents              = make(map[string]*agentState)
+	taskAgents          = make(map[string]string) // taskID -> агент, выполняющий задачу
+	taskLeases          = make(map[string]taskLease)
+	mu                  sync.RWMutex
+	calc                = calculator.NewCalculator()
 )
 
 type stackItem struct {
@@ -41,148 +66,278 @@ func ResetState() {
 	taskResults = make(map[string]float64)
 	taskToExpression = make(map[string]string)
 	expressionTasks = make(map[string][]string)
-	dependsOnTask = make(map[string]string)
-	calc = calculator.NewCalculator()
+	dependsOnTask = make(map[string]taskDeps)
+	taskTimings = make(map[string]*taskTiming)
+	expressionSpans = make(map[string]*tracing.Span)
+	pendingByClient = make(map[string]int)
+	expressionClients = make(map[string]string)
+	batches = make(map[string]*batch)
+	expressionDone = make(map[string]chan struct{})
+	idempotencyKeys = make(map[string]*idempotencyRecord)
+	idempotencyLastSweep = time.Time{}
+	batchesLastSweep = time.Time{}
+	results = newResultCache()
+	inflightExpressions = make(map[string]string)
+	expressionCacheKeys = make(map[string]string)
+	expressionFollowers = make(map[string][]string)
+	subtreeTasks = make(map[string]string)
+	taskKeys = make(map[string]string)
+	taskRefs = make(map[string]int)
+	taskWaiters = make(map[string][]string)
+	reusedOperations = make(map[string]int)
+	agents = make(map[string]*agentState)
+	taskAgents = make(map[string]string)
+	taskLeases = make(map[string]taskLease)
 }
 
-func HandleCalculate(w http.ResponseWriter, r *http.Request) {
-	var req types.CalculateRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
+// submitError - ошибка приёма выражения вместе с HTTP-статусом ответа
+type submitError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *submitError) Error() string {
+	return e.message
+}
+
+// decodeRequest читает JSON-тело запроса не длиннее maxBytes.
+// При ошибке отправляет ответ клиенту и возвращает false
+func decodeRequest(w http.ResponseWriter, r *http.Request, maxBytes int64, v any) bool {
+	if maxBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
 	}
 
-	exprID := uuid.New().String()
-	expr := types.Expression{
-		ID:       exprID,
-		Original: req.Expression,
-		Status:   "PROCESSING",
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			api.SendErrorResponse(w, r, http.StatusRequestEntityTooLarge, api.CodeBodyTooLarge, "Request body too large")
+			return false
+		}
+		api.SendErrorResponse(w, r, http.StatusBadRequest, api.CodeInvalidBody, "Invalid request body")
+		return false
 	}
+	return true
+}
 
-	testCalc := calculator.NewCalculator()
-	calculatedResult, err := testCalc.Calculate(req.Expression)
+// parseExpression проверяет размер выражения, вычисляет его и строит дерево операций.
+// Общая проверка для распределённого /calculate и локального /evaluate
+func parseExpression(ctx context.Context, expression string) (*planNode, float64, *submitError) {
+	if err := checkExpressionSize(expression, getLimits()); err != nil {
+		return nil, 0, &submitError{http.StatusUnprocessableEntity, api.CodeExpressionTooLarge, "Expression too large: " + err.Error()}
+	}
+
+	parser := calculator.NewCalculator()
+	result, err := parser.Calculate(expression)
 	if err != nil {
+		slog.WarnContext(ctx, "expression rejected", "expression", expression, "error", err)
 		if strings.Contains(err.Error(), "division by zero") ||
 			strings.Contains(err.Error(), "invalid character") ||
 			strings.Contains(err.Error(), "mismatched parentheses") ||
 			strings.Contains(err.Error(), "invalid expression") ||
 			strings.Contains(err.Error(), "empty expression") {
-			http.Error(w, "Invalid expression: "+err.Error(), http.StatusUnprocessableEntity) // 422
-			return
+			return nil, 0, &submitError{http.StatusUnprocessableEntity, api.CodeInvalidExpression, "Invalid expression: " + err.Error()} // 422
 		}
 
-		http.Error(w, "Error processing expression: "+err.Error(), http.StatusInternalServerError)
-		return
+		return nil, 0, &submitError{http.StatusInternalServerError, api.CodeInternal, "Error processing expression: " + err.Error()}
 	}
 
-	if err := calc.Tokenize(req.Expression); err != nil {
-		http.Error(w, "Invalid expression: "+err.Error(), http.StatusUnprocessableEntity)
+	// Calculate уже разобрал выражение, поэтому повторный ToRPN не может завершиться ошибкой
+	rpn, err := parser.ToRPN()
+	if err != nil {
+		return nil, 0, &submitError{http.StatusBadRequest, api.CodeInvalidExpression, "Invalid expression: " + err.Error()}
+	}
+	return buildPlanTree(rpn), result, nil
+}
+
+func HandleCalculate(w http.ResponseWriter, r *http.Request) {
+	wait, fromPrefer, err := parseWait(r)
+	if err != nil {
+		api.SendErrorResponse(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "Invalid wait: "+err.Error())
 		return
 	}
 
-	rpn, err := calc.ToRPN()
+	key, err := idempotencyKey(r)
 	if err != nil {
-		http.Error(w, "Invalid expression: "+err.Error(), http.StatusBadRequest)
+		api.SendErrorResponse(w, r, http.StatusBadRequest, api.CodeInvalidParameter, err.Error())
 		return
 	}
 
-	mu.Lock()
-	defer mu.Unlock()
+	limits := getLimits()
+	var req types.CalculateRequest
+	if !decodeRequest(w, r, limits.MaxBodyBytes, &req) {
+		return
+	}
 
-	if len(rpn) == 1 && rpn[0].Type == calculator.Number {
-		expr.Status = "COMPLETED"
-		expr.Result = calculatedResult
-		expressions[exprID] = expr
+	var expr types.Expression
+	var submitErr *submitError
+	if limits.IdempotencyTTL > 0 && key != "" {
+		expr, submitErr = submitIdempotent(w, r, key, req, limits.IdempotencyTTL)
+	} else {
+		expr, submitErr = submitExpression(r, req)
+	}
+	if submitErr != nil {
+		api.SendErrorResponse(w, r, submitErr.status, submitErr.code, submitErr.message)
+		return
+	}
 
+	if wait <= 0 {
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"id": exprID})
+		json.NewEncoder(w).Encode(map[string]string{"id": expr.ID})
 		return
 	}
 
-	expressions[exprID] = expr
+	if fromPrefer {
+		w.Header().Set("Preference-Applied", "wait="+strconv.Itoa(int(wait.Seconds())))
+	}
 
-	var taskIDs []string
-	var stack []stackItem
-
-	for _, token := range rpn {
-		switch token.Type {
-		case calculator.Number:
-			num, _ := strconv.ParseFloat(token.Value, 64)
-			stack = append(stack, stackItem{
-				value: num,
-				isNum: true,
-			})
-		case calculator.Operator:
-			if len(stack) < 2 {
-				http.Error(w, "Invalid expression", http.StatusBadRequest)
-				return
-			}
+	final, done := waitForExpression(r.Context(), expr.ID, wait)
+	w.Header().Set("Content-Type", "application/json")
+	if !done {
+		w.Header().Set("Location", "/api/v1/expressions/"+expr.ID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"id": expr.ID})
+		return
+	}
+	json.NewEncoder(w).Encode(final)
+}
 
-			taskID := uuid.New().String()
+// submitIdempotent принимает выражение один раз на Idempotency-Key.
+// Повтор с тем же телом возвращает исходное выражение с заголовком Idempotent-Replayed
+func submitIdempotent(w http.ResponseWriter, r *http.Request, key string, req types.CalculateRequest, ttl time.Duration) (types.Expression, *submitError) {
+	exprID, submitErr := reserveIdempotencyKey(key, idempotencyFingerprint(req), ttl, time.Now())
+	if submitErr != nil {
+		return types.Expression{}, submitErr
+	}
 
-			rightOp := stack[len(stack)-1]
-			leftOp := stack[len(stack)-2]
-			stack = stack[:len(stack)-2]
+	if exprID != "" {
+		mu.RLock()
+		expr := expressions[exprID]
+		mu.RUnlock()
 
-			task := types.Task{
-				ID:        taskID,
-				Operation: token.Value,
-			}
+		w.Header().Set("Idempotent-Replayed", "true")
+		return expr, nil
+	}
 
-			if token.Value == "*" || token.Value == "/" {
-				task.Priority = 2
-			} else {
-				task.Priority = 1
-			}
+	expr, submitErr := submitExpression(r, req)
+	if submitErr != nil {
+		releaseIdempotencyKey(key)
+		return expr, submitErr
+	}
+	completeIdempotencyKey(key, expr.ID, ttl)
+	return expr, nil
+}
 
-			if leftOp.isNum {
-				task.Arg1 = leftOp.value
-			} else {
-				task.Arg1 = 0
-				dependsOnTask[taskID] = leftOp.taskID
-			}
+// submitExpression проверяет выражение, сохраняет его и разбивает на задачи для агентов
+func submitExpression(r *http.Request, req types.CalculateRequest) (types.Expression, *submitError) {
+	expression := req.Expression
+	if req.CallbackURL != "" {
+		if err := validateCallbackURL(r.Context(), req.CallbackURL); err != nil {
+			return types.Expression{}, &submitError{http.StatusUnprocessableEntity, api.CodeInvalidCallbackURL, "Invalid callback_url: " + err.Error()}
+		}
+	}
 
-			if rightOp.isNum {
-				task.Arg2 = rightOp.value
-			} else {
-				task.Arg2 = 0
-				if _, exists := dependsOnTask[taskID]; !exists {
-					dependsOnTask[taskID] = rightOp.taskID
-				}
-			}
+	requestID := logging.RequestID(r.Context())
+	if requestID == "" {
+		requestID = logging.NewRequestID()
+	}
 
-			tasks[taskID] = task
-			taskToExpression[taskID] = exprID
-			taskIDs = append(taskIDs, taskID)
+	exprID := uuid.New().String()
+	expr := types.Expression{
+		ID:        exprID,
+		Original:  expression,
+		Status:    types.StatusProcessing,
+		CreatedAt: time.Now(),
+		RequestID: requestID,
+		OwnerID:   auth.UserID(r.Context()),
+	}
+	if req.CallbackURL != "" {
+		expr.Callback = &types.WebhookDelivery{URL: req.CallbackURL, Status: webhookPending}
+	}
+	ctx := logging.With(logging.WithRequestID(r.Context(), requestID), "expression_id", exprID)
+
+	tree, calculatedResult, submitErr := parseExpression(ctx, expression)
+	if submitErr != nil {
+		return expr, submitErr
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
 
-			stack = append(stack, stackItem{
-				taskID: taskID,
-				isNum:  false,
-			})
+	if !tree.isNum {
+		if reused, ok := reuseResult(r, expr, tree.key); ok {
+			slog.InfoContext(ctx, "expression reused existing result", "expression", expression, "status", reused.Status)
+			return reused, nil
 		}
 	}
 
-	expressionTasks[exprID] = taskIDs
+	if !tree.isNum && !acquirePending(clientID(r), exprID) {
+		slog.WarnContext(ctx, "too many pending expressions")
+		return expr, &submitError{http.StatusTooManyRequests, api.CodeTooManyPending, "Too many pending expressions"}
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"id": exprID})
+	span := startExpressionSpan(r, expr)
+
+	if tree.isNum {
+		completedAt := expr.CreatedAt
+		expr.Status = types.StatusCompleted
+		expr.Result = calculatedResult
+		expr.CompletedAt = &completedAt
+		expr.Metrics = &types.ExpressionMetrics{}
+		expressions[exprID] = expr
+		expressionSpans[exprID] = span
+		finishExpressionSpan(expr)
+		scheduleWebhook(expr)
+		slog.InfoContext(ctx, "expression completed without tasks", "expression", expression)
+		return expr, nil
+	}
+
+	expressions[exprID] = expr
+	expressionSpans[exprID] = span
+	expressionDone[exprID] = make(chan struct{})
+
+	planner := newTaskPlanner(expr)
+	planner.plan(tree)
+
+	expressionTasks[exprID] = planner.taskIDs
+	reusedOperations[exprID] = planner.reused
+	trackInflight(exprID, tree.key)
+	slog.InfoContext(ctx, "expression accepted", "expression", expression,
+		"tasks", len(planner.taskIDs), "reused_operations", planner.reused)
+
+	// Все задачи могли быть взяты из других выражений и уже выполнены
+	if expressionReady(exprID) {
+		completeExpression(ctx, exprID)
+	}
+
+	return expressions[exprID], nil
 }
 
 func HandleGetExpressions(w http.ResponseWriter, r *http.Request) {
-	mu.RLock()
-	defer mu.RUnlock()
+	query, err := parseExpressionQuery(r.URL.Query())
+	if err != nil {
+		api.SendErrorResponse(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "Invalid query: "+err.Error())
+		return
+	}
+	query.ownerID = auth.UserID(r.Context())
+
+	now := time.Now()
 
-	var expressionsList []types.Expression
+	// Метрики считаются только для выражений страницы
+	mu.RLock()
+	expressionsList := make([]types.Expression, 0, len(expressions))
 	for _, expr := range expressions {
 		expressionsList = append(expressionsList, expr)
 	}
-
-	sort.Slice(expressionsList, func(i, j int) bool {
-		return expressionsList[i].ID < expressionsList[j].ID
-	})
+	page, nextCursor := query.apply(expressionsList)
+	for i := range page {
+		page[i] = withLiveMetrics(page[i], now)
+	}
+	mu.RUnlock()
 
 	response := types.ExpressionResponse{
-		Expressions: expressionsList,
+		Expressions: page,
+		NextCursor:  nextCursor,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -195,10 +350,14 @@ func HandleGetExpression(w http.ResponseWriter, r *http.Request) {
 
 	mu.RLock()
 	expr, exists := expressions[id]
+	exists = exists && expr.OwnerID == auth.UserID(r.Context())
+	if exists {
+		expr = withLiveMetrics(expr, time.Now())
+	}
 	mu.RUnlock()
 
 	if !exists {
-		http.Error(w, "Expression not found", http.StatusNotFound)
+		api.SendErrorResponse(w, r, http.StatusNotFound, api.CodeExpressionNotFound, "Expression not found")
 		return
 	}
 
@@ -207,117 +366,250 @@ func HandleGetExpression(w http.ResponseWriter, r *http.Request) {
 }
 
 func HandleGetTask(w http.ResponseWriter, r *http.Request) {
+	version, ok := negotiateProtocol(w, r)
+	if !ok {
+		return
+	}
+
 	mu.Lock()
 	defer mu.Unlock()
 
-	for id, task := range tasks {
-		if task.Priority == 2 {
-			dependTaskID, hasDependency := dependsOnTask[id]
-			if !hasDependency {
-				delete(tasks, id)
-				w.Header().Set("Content-Type", "application/json")
-				json.NewEncoder(w).Encode(task)
-				return
+	now := time.Now()
+	agent := trackAgent(r, version, now)
+	state := agents[agent]
+	requeueExpiredTasks(now)
+
+	id, task, ok := nextReadyTask(state)
+	if !ok {
+		w.Header().Set(types.QueueDepthHeader, "0")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	task, traceparent := leaseTask(agent, id, task)
+	w.Header().Set(types.QueueDepthHeader, strconv.Itoa(readyTaskCount(state)))
+	if traceparent != "" {
+		w.Header().Set(tracing.TraceparentHeader, traceparent)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(task)
+}
+
+// nextReadyTask ищет готовую задачу, которую может выполнить агент: сначала умножение и деление,
+// затем сложение и вычитание. Аргументы задачи заполняются результатами зависимостей. Вызывается под mu.Lock()
+func nextReadyTask(agent *agentState) (string, types.Task, bool) {
+	for _, priority := range []int{2, 1} {
+		for id, task := range tasks {
+			if task.Priority != priority || !agent.canRun(task) {
+				continue
 			}
 
-			if result, ok := taskResults[dependTaskID]; ok {
-				if task.Arg1 == 0 {
-					task.Arg1 = result
-				} else {
-					task.Arg2 = result
-				}
-
-				delete(tasks, id)
-				delete(dependsOnTask, id)
-				w.Header().Set("Content-Type", "application/json")
-				json.NewEncoder(w).Encode(task)
-				return
+			deps := dependsOnTask[id]
+			ready := true
+			if deps.arg1 != "" {
+				result, ok := taskResults[deps.arg1]
+				task.Arg1, ready = result, ready && ok
+			}
+			if deps.arg2 != "" {
+				result, ok := taskResults[deps.arg2]
+				task.Arg2, ready = result, ready && ok
+			}
+
+			if ready {
+				return id, task, true
 			}
 		}
 	}
+	return "", types.Task{}, false
+}
 
+// readyTaskCount - задачи, аргументы которых уже известны и которые может выполнить агент.
+// По этому числу агенты подбирают количество воркеров. Вызывается под mu.Lock()
+func readyTaskCount(agent *agentState) int {
+	count := 0
 	for id, task := range tasks {
-		if task.Priority == 1 {
-			dependTaskID, hasDependency := dependsOnTask[id]
-			if !hasDependency {
-				delete(tasks, id)
-				w.Header().Set("Content-Type", "application/json")
-				json.NewEncoder(w).Encode(task)
-				return
-			}
-
-			if result, ok := taskResults[dependTaskID]; ok {
-				if task.Arg1 == 0 {
-					task.Arg1 = result
-				} else {
-					task.Arg2 = result
-				}
-
-				delete(tasks, id)
-				delete(dependsOnTask, id)
-				w.Header().Set("Content-Type", "application/json")
-				json.NewEncoder(w).Encode(task)
-				return
-			}
+		if !agent.canRun(task) {
+			continue
+		}
+		deps := dependsOnTask[id]
+		_, arg1Ready := taskResults[deps.arg1]
+		_, arg2Ready := taskResults[deps.arg2]
+		if (deps.arg1 == "" || arg1Ready) && (deps.arg2 == "" || arg2Ready) {
+			count++
 		}
 	}
+	return count
+}
 
-	w.WriteHeader(http.StatusNoContent)
+// leaseTask выдаёт задачу агенту и убирает её из очереди. Возвращает задачу с временем операции
+// и контекст трассировки для агента. Вызывается под mu.Lock()
+func leaseTask(agent, id string, task types.Task) (types.Task, string) {
+	now := time.Now()
+	recordTaskDispatched(id, now)
+	holdLease(agent, id, now)
+	task.OperationTime = int(getOperationTimes().For(task.Operation).Milliseconds())
+	taskAgents[id] = agent
+	traceparent := traceDispatch(id, task)
+	delete(tasks, id)
+	delete(dependsOnTask, id)
+
+	slog.Info("task dispatched",
+		"request_id", task.RequestID,
+		"expression_id", task.ExpressionID,
+		"task_id", id,
+		"agent_id", agent,
+		"operation", task.Operation)
+
+	return task, traceparent
 }
 
 func HandleSubmitTaskResult(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	version, ok := negotiateProtocol(w, r)
+	if !ok {
+		return
+	}
+
 	var result types.TaskResult
 	if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		slog.WarnContext(ctx, "invalid task result body", "error", err)
+		taskResultsTotal.Inc(resultInvalidBody)
+		api.SendErrorResponse(w, r, http.StatusBadRequest, api.CodeInvalidBody, "Invalid request body")
+		return
+	}
+	if result.Error != "" && version < types.ProtocolV2 {
+		taskResultsTotal.Inc(resultInvalidBody)
+		api.SendErrorResponse(w, r, http.StatusBadRequest, api.CodeInvalidBody, "Task error requires protocol version 2")
 		return
 	}
 
 	mu.Lock()
 	defer mu.Unlock()
 
-	taskResults[result.ID] = result.Result
+	trackAgent(r, version, time.Now())
+	if err := acceptTaskResult(ctx, result, r.Header.Get(tracing.TraceparentHeader)); err != nil {
+		api.SendErrorResponse(w, r, err.status, err.code, err.message)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// acceptTaskResult сохраняет результат задачи и завершает выражения, которым он был нужен.
+// traceparent - контекст трассировки вычисления у агента. Вызывается под mu.Lock()
+func acceptTaskResult(ctx context.Context, result types.TaskResult, traceparent string) *submitError {
+	span := startResultSpan(traceparent, result.ID)
+	defer span.End()
+
+	now := time.Now()
+	recordAgentResult(result.ID, result.Error != "")
+	recordTaskFinished(result.ID, now)
+
+	taskCtx := logging.With(ctx, "task_id", result.ID)
 
 	exprID, exists := taskToExpression[result.ID]
 	if !exists {
-		http.Error(w, "Task not found", http.StatusNotFound)
-		return
+		slog.WarnContext(taskCtx, "result for unknown task")
+		span.SetAttr("error", "task not found")
+		taskResultsTotal.Inc(resultUnknownTask)
+		return &submitError{status: http.StatusNotFound, code: api.CodeTaskNotFound, message: "Task not found"}
+	}
+
+	taskCtx = logging.With(taskCtx, "expression_id", exprID)
+	span.SetAttr("expression_id", exprID)
+	endLease(result.ID)
+
+	if result.Error != "" {
+		slog.WarnContext(taskCtx, "task failed", "error", result.Error)
+		span.SetAttr("error", result.Error)
+		taskResultsTotal.Inc(resultFailed)
+		for _, id := range taskExpressionIDs(result.ID) {
+			failExpression(logging.With(ctx, "expression_id", id), id, result.Error)
+		}
+		return nil
 	}
 
+	taskResults[result.ID] = result.Result
+	taskResultsTotal.Inc(resultAccepted)
+	storeSubexpression(result.ID, result.Result)
+	slog.DebugContext(taskCtx, "task result received", "result", result.Result)
+
+	for _, id := range taskExpressionIDs(result.ID) {
+		if expressionReady(id) {
+			completeExpression(logging.With(ctx, "expression_id", id), id)
+		}
+	}
+	return nil
+}
+
+// expressionReady - все задачи выражения, включая переиспользованные, получили результат
+func expressionReady(exprID string) bool {
 	taskIDs, ok := expressionTasks[exprID]
 	if !ok {
-		http.Error(w, "Expression tasks not found", http.StatusNotFound)
-		return
+		return false
 	}
-
-	allTasksCompleted := true
 	for _, taskID := range taskIDs {
 		if _, ok := taskResults[taskID]; !ok {
-			allTasksCompleted = false
-			break
+			return false
 		}
 	}
+	return true
+}
 
-	if allTasksCompleted {
-		expr := expressions[exprID]
-		finalResult, err := calculator.Calc(expr.Original)
-		if err != nil {
-			expr.Status = "ERROR"
-			expressions[exprID] = expr
-			log.Printf("Error calculating expression %s: %v", expr.Original, err)
-		} else {
-			expr.Status = "COMPLETED"
-			expr.Result = finalResult
-			expressions[exprID] = expr
-		}
+// completeExpression вычисляет итог выражения и освобождает его задачи. Вызывается под mu.Lock()
+func completeExpression(ctx context.Context, exprID string) {
+	taskIDs := expressionTasks[exprID]
 
-		for _, taskID := range taskIDs {
-			delete(taskResults, taskID)
-			delete(taskToExpression, taskID)
-			delete(dependsOnTask, taskID)
-			delete(tasks, taskID)
-		}
-		delete(expressionTasks, exprID)
+	expr := expressions[exprID]
+	completedAt := time.Now()
+	expr.CompletedAt = &completedAt
+	expr.Metrics = expressionMetrics(expr, taskIDs, completedAt)
+	finalResult, err := calculator.Calc(expr.Original)
+	if err != nil {
+		expr.Status = types.StatusError
+		expr.Error = err.Error()
+		expressions[exprID] = expr
+		slog.ErrorContext(ctx, "expression failed", "expression", expr.Original, "error", err)
+	} else {
+		expr.Status = types.StatusCompleted
+		expr.Result = finalResult
+		expressions[exprID] = expr
+		slog.InfoContext(ctx, "expression completed", "result", finalResult, "wall_time_ms", expr.Metrics.WallTimeMs)
 	}
+	finishExpression(expr, taskIDs)
+}
 
-	w.WriteHeader(http.StatusOK)
+// failExpression завершает выражение с ошибкой, о которой сообщил агент. Вызывается под mu.Lock()
+func failExpression(ctx context.Context, exprID, reason string) {
+	taskIDs, ok := expressionTasks[exprID]
+	if !ok {
+		return // выражение уже завершено из-за ошибки другой задачи
+	}
+
+	expr := expressions[exprID]
+	completedAt := time.Now()
+	expr.CompletedAt = &completedAt
+	expr.Metrics = expressionMetrics(expr, taskIDs, completedAt)
+	expr.Status = types.StatusError
+	expr.Error = reason
+	expressions[exprID] = expr
+	slog.ErrorContext(ctx, "expression failed", "expression", expr.Original, "error", reason)
+
+	finishExpression(expr, taskIDs)
+}
+
+// finishExpression оповещает ожидающих о завершённом выражении и освобождает его задачи. Вызывается под mu.Lock()
+func finishExpression(expr types.Expression, taskIDs []string) {
+	exprID := expr.ID
+	finishExpressionSpan(expr)
+	releasePending(exprID)
+	notifyExpressionDone(exprID)
+	scheduleWebhook(expr)
+	completeShared(expr)
+
+	for _, taskID := range taskIDs {
+		releaseTask(taskID)
+	}
+	delete(expressionTasks, exprID)
+	delete(reusedOperations, exprID)
 }
@@ -0,0 +1,97 @@
+package orchestrator
+
+import (
+	"calculator-service/internal/api"
+	"calculator-service/internal/types"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// idempotencyRecord - выражение, созданное запросом с Idempotency-Key
+type idempotencyRecord struct {
+	fingerprint string
+	exprID      string // пусто, пока первый запрос с этим ключом ещё обрабатывается
+	expiresAt   time.Time
+}
+
+var idempotencyLastSweep time.Time
+
+// idempotencyKey возвращает ключ из заголовка и область его действия: ключи разных клиентов не пересекаются
+func idempotencyKey(r *http.Request) (string, error) {
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if key == "" {
+		return "", nil
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return "", errors.New("Idempotency-Key must be at most 255 characters")
+	}
+	for _, c := range key {
+		if c < 0x21 || c > 0x7e {
+			return "", errors.New("Idempotency-Key must contain only printable ASCII characters")
+		}
+	}
+	return clientID(r) + "|" + key, nil
+}
+
+func idempotencyFingerprint(req types.CalculateRequest) string {
+	sum := sha256.Sum256([]byte(req.Expression + "\n" + req.CallbackURL))
+	return hex.EncodeToString(sum[:])
+}
+
+// reserveIdempotencyKey возвращает ID выражения, уже созданного с этим ключом.
+// Если ключ новый, резервирует его до вызова completeIdempotencyKey или releaseIdempotencyKey
+func reserveIdempotencyKey(key, fingerprint string, ttl time.Duration, now time.Time) (string, *submitError) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if now.Sub(idempotencyLastSweep) > time.Minute {
+		for k, rec := range idempotencyKeys {
+			if rec.exprID != "" && now.After(rec.expiresAt) {
+				delete(idempotencyKeys, k)
+			}
+		}
+		idempotencyLastSweep = now
+	}
+
+	if rec, ok := idempotencyKeys[key]; ok && (rec.exprID == "" || !now.After(rec.expiresAt)) {
+		if rec.fingerprint != fingerprint {
+			return "", &submitError{http.StatusConflict, api.CodeIdempotencyConflict, "Idempotency-Key was already used with a different request body"}
+		}
+		if rec.exprID == "" {
+			return "", &submitError{http.StatusConflict, api.CodeIdempotencyInFlight, "A request with this Idempotency-Key is still being processed"}
+		}
+		return rec.exprID, nil
+	}
+
+	idempotencyKeys[key] = &idempotencyRecord{fingerprint: fingerprint, expiresAt: now.Add(ttl)}
+	return "", nil
+}
+
+func completeIdempotencyKey(key, exprID string, ttl time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if rec, ok := idempotencyKeys[key]; ok {
+		rec.exprID = exprID
+		rec.expiresAt = time.Now().Add(ttl)
+	}
+}
+
+// releaseIdempotencyKey снимает резерв, если выражение не было принято, чтобы клиент мог повторить запрос
+func releaseIdempotencyKey(key string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if rec, ok := idempotencyKeys[key]; ok && rec.exprID == "" {
+		delete(idempotencyKeys, key)
+	}
+}
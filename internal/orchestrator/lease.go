@@ -0,0 +1,69 @@
+package orchestrator
+
+import (
+	"calculator-service/internal/types"
+	"log/slog"
+	"time"
+)
+
+// taskLease - задача, выданная агенту. Если результат не пришёл до expiresAt, задача
+// возвращается в очередь: агент мог остановиться, не начав её, или не доставить результат
+type taskLease struct {
+	task      types.Task
+	deps      taskDeps
+	agent     string
+	expiresAt time.Time
+}
+
+// Функции ниже вызываются под mu.Lock()
+
+// holdLease запоминает выданную задачу в том виде, в каком она стояла в очереди.
+// Аренда длится время операции и TASK_LEASE_TIMEOUT сверху, нулевой таймаут отключает возврат задач
+func holdLease(agent, id string, now time.Time) {
+	timeout := getLimits().TaskLeaseTimeout
+	if timeout <= 0 {
+		return
+	}
+	task := tasks[id]
+	taskLeases[id] = taskLease{
+		task:      task,
+		deps:      dependsOnTask[id],
+		agent:     agent,
+		expiresAt: now.Add(getOperationTimes().For(task.Operation) + timeout),
+	}
+}
+
+// endLease снимает аренду задачи, результат которой получен. Задача, которая уже вернулась
+// в очередь, но ещё не выдана снова, из очереди убирается
+func endLease(id string) {
+	delete(taskLeases, id)
+	delete(tasks, id)
+	delete(dependsOnTask, id)
+}
+
+// requeueExpiredTasks возвращает в очередь задачи, аренда которых истекла
+func requeueExpiredTasks(now time.Time) {
+	for id, lease := range taskLeases {
+		if now.Before(lease.expiresAt) {
+			continue
+		}
+		delete(taskLeases, id)
+		delete(taskAgents, id)
+
+		tasks[id] = lease.task
+		if len(lease.deps.ids()) > 0 {
+			dependsOnTask[id] = lease.deps
+		}
+		if timing, ok := taskTimings[id]; ok {
+			timing.dispatchedAt = time.Time{}
+		}
+		leasesExpired.Inc(lease.task.Operation)
+
+		slog.Warn("task lease expired, task requeued",
+			"request_id", lease.task.RequestID,
+			"expression_id", lease.task.ExpressionID,
+			"task_id", id,
+			"agent_id", lease.agent,
+			"operation", lease.task.Operation)
+	}
+}
@@ -0,0 +1,337 @@
+package orchestrator
+
+import (
+	"calculator-service/internal/api"
+	"calculator-service/internal/auth"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limits - ограничения на размер выражений и частоту запросов.
+// Нулевое значение отключает соответствующую проверку
+type Limits struct {
+	MaxBodyBytes        int64
+	MaxExpressionLength int
+	MaxOperators        int
+	MaxNestingDepth     int
+	MaxPending          int
+	MaxBatchSize        int
+	MaxBatchBodyBytes   int64
+	MaxWait             time.Duration
+	BatchTTL            time.Duration
+	TaskLeaseTimeout    time.Duration
+	IdempotencyTTL      time.Duration
+	CacheTTL            time.Duration
+	CacheSize           int
+	RateLimitRPS        float64
+	RateLimitBurst      int
+	AuthRateLimitRPS    float64
+	AuthRateLimitBurst  int
+	MaxUsers            int
+}
+
+func DefaultLimits() Limits {
+	return Limits{
+		MaxBodyBytes:        64 << 10,
+		MaxExpressionLength: 10000,
+		MaxOperators:        1000,
+		MaxNestingDepth:     100,
+		MaxPending:          100,
+		MaxBatchSize:        500,
+		MaxBatchBodyBytes:   1 << 20,
+		MaxWait:             60 * time.Second,
+		BatchTTL:            24 * time.Hour,
+		TaskLeaseTimeout:    time.Minute,
+		IdempotencyTTL:      24 * time.Hour,
+		CacheTTL:            10 * time.Minute,
+		CacheSize:           10000,
+		RateLimitRPS:        5,
+		RateLimitBurst:      20,
+		AuthRateLimitRPS:    0.2,
+		AuthRateLimitBurst:  5,
+		MaxUsers:            10000,
+	}
+}
+
+// LoadLimits читает MAX_BODY_BYTES, MAX_EXPRESSION_LENGTH, MAX_OPERATORS,
+// MAX_NESTING_DEPTH, MAX_PENDING_EXPRESSIONS, MAX_BATCH_SIZE, MAX_BATCH_BODY_BYTES,
+// MAX_WAIT, BATCH_TTL, TASK_LEASE_TIMEOUT, IDEMPOTENCY_TTL, RESULT_CACHE_TTL, RESULT_CACHE_SIZE, RATE_LIMIT_RPS,
+// RATE_LIMIT_BURST, AUTH_RATE_LIMIT_RPS, AUTH_RATE_LIMIT_BURST и MAX_USERS. Возвращает все ошибки сразу
+func LoadLimits(get func(key string) string) (Limits, error) {
+	l := DefaultLimits()
+	var errs []error
+
+	ints := []struct {
+		key string
+		dst *int
+	}{
+		{"MAX_EXPRESSION_LENGTH", &l.MaxExpressionLength},
+		{"MAX_OPERATORS", &l.MaxOperators},
+		{"MAX_NESTING_DEPTH", &l.MaxNestingDepth},
+		{"MAX_PENDING_EXPRESSIONS", &l.MaxPending},
+		{"MAX_BATCH_SIZE", &l.MaxBatchSize},
+		{"RESULT_CACHE_SIZE", &l.CacheSize},
+		{"RATE_LIMIT_BURST", &l.RateLimitBurst},
+		{"AUTH_RATE_LIMIT_BURST", &l.AuthRateLimitBurst},
+		{"MAX_USERS", &l.MaxUsers},
+	}
+	for _, v := range ints {
+		raw := get(v.key)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			errs = append(errs, fmt.Errorf("invalid %s: %q, expected a non-negative integer", v.key, raw))
+			continue
+		}
+		*v.dst = n
+	}
+
+	int64s := []struct {
+		key string
+		dst *int64
+	}{
+		{"MAX_BODY_BYTES", &l.MaxBodyBytes},
+		{"MAX_BATCH_BODY_BYTES", &l.MaxBatchBodyBytes},
+	}
+	for _, v := range int64s {
+		raw := get(v.key)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 0 {
+			errs = append(errs, fmt.Errorf("invalid %s: %q, expected a non-negative integer", v.key, raw))
+			continue
+		}
+		*v.dst = n
+	}
+
+	durations := []struct {
+		key string
+		dst *time.Duration
+	}{
+		{"MAX_WAIT", &l.MaxWait},
+		{"BATCH_TTL", &l.BatchTTL},
+		{"TASK_LEASE_TIMEOUT", &l.TaskLeaseTimeout},
+		{"IDEMPOTENCY_TTL", &l.IdempotencyTTL},
+		{"RESULT_CACHE_TTL", &l.CacheTTL},
+	}
+	for _, v := range durations {
+		raw := get(v.key)
+		if raw == "" {
+			continue
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("invalid %s: %q, expected a duration such as 30s or 10m", v.key, raw))
+			continue
+		}
+		*v.dst = d
+	}
+
+	rates := []struct {
+		key string
+		dst *float64
+	}{
+		{"RATE_LIMIT_RPS", &l.RateLimitRPS},
+		{"AUTH_RATE_LIMIT_RPS", &l.AuthRateLimitRPS},
+	}
+	for _, v := range rates {
+		raw := get(v.key)
+		if raw == "" {
+			continue
+		}
+		rps, err := strconv.ParseFloat(raw, 64)
+		if err != nil || rps < 0 {
+			errs = append(errs, fmt.Errorf("invalid %s: %q, expected a non-negative number", v.key, raw))
+			continue
+		}
+		*v.dst = rps
+	}
+
+	if l.RateLimitRPS > 0 && l.RateLimitBurst < 1 {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_BURST must be at least 1 when RATE_LIMIT_RPS is set"))
+	}
+	if l.AuthRateLimitRPS > 0 && l.AuthRateLimitBurst < 1 {
+		errs = append(errs, fmt.Errorf("AUTH_RATE_LIMIT_BURST must be at least 1 when AUTH_RATE_LIMIT_RPS is set"))
+	}
+
+	return l, errors.Join(errs...)
+}
+
+var (
+	limitsMu      sync.RWMutex
+	currentLimits = DefaultLimits()
+	limiter       = newRateLimiter()
+	authLimiter   = newRateLimiter()
+)
+
+func SetLimits(l Limits) {
+	limitsMu.Lock()
+	defer limitsMu.Unlock()
+
+	currentLimits = l
+	limiter = newRateLimiter()
+	authLimiter = newRateLimiter()
+	auth.SetMaxUsers(l.MaxUsers)
+}
+
+func getLimits() Limits {
+	limitsMu.RLock()
+	defer limitsMu.RUnlock()
+	return currentLimits
+}
+
+// checkExpressionSize проверяет длину, число операторов и глубину скобок до разбора выражения
+func checkExpressionSize(expression string, l Limits) error {
+	if l.MaxExpressionLength > 0 && len(expression) > l.MaxExpressionLength {
+		return fmt.Errorf("expression is longer than %d characters", l.MaxExpressionLength)
+	}
+
+	operators, depth, maxDepth := 0, 0, 0
+	for _, c := range expression {
+		switch c {
+		case '+', '-', '*', '/':
+			operators++
+		case '(':
+			depth++
+			if depth > maxDepth {
+				maxDepth = depth
+			}
+		case ')':
+			depth--
+		}
+	}
+
+	if l.MaxOperators > 0 && operators > l.MaxOperators {
+		return fmt.Errorf("expression has more than %d operators", l.MaxOperators)
+	}
+	if l.MaxNestingDepth > 0 && maxDepth > l.MaxNestingDepth {
+		return fmt.Errorf("expression is nested deeper than %d levels", l.MaxNestingDepth)
+	}
+	return nil
+}
+
+// clientID - пользователь из аутентификации, иначе IP-адрес клиента
+func clientID(r *http.Request) string {
+	if userID := auth.UserID(r.Context()); userID != "" {
+		return "user:" + userID
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// Счётчики незавершённых выражений по клиентам. Вызываются под mu.Lock()
+
+func acquirePending(client, exprID string) bool {
+	if limit := getLimits().MaxPending; limit > 0 && pendingByClient[client] >= limit {
+		return false
+	}
+	pendingByClient[client]++
+	expressionClients[exprID] = client
+	return true
+}
+
+func releasePending(exprID string) {
+	client, ok := expressionClients[exprID]
+	if !ok {
+		return
+	}
+	delete(expressionClients, exprID)
+
+	pendingByClient[client]--
+	if pendingByClient[client] <= 0 {
+		delete(pendingByClient, client)
+	}
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	mu          sync.Mutex
+	buckets     map[string]*tokenBucket
+	lastCleanup time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*tokenBucket)}
+}
+
+// allow забирает токен клиента. Если токенов нет, возвращает время до появления следующего
+func (rl *rateLimiter) allow(client string, rps float64, burst int, now time.Time) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	capacity := float64(burst)
+	if now.Sub(rl.lastCleanup) > time.Minute {
+		refill := time.Duration(capacity / rps * float64(time.Second))
+		for id, b := range rl.buckets {
+			if now.Sub(b.last) > refill {
+				delete(rl.buckets, id)
+			}
+		}
+		rl.lastCleanup = now
+	}
+
+	b, ok := rl.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: capacity, last: now}
+		rl.buckets[client] = b
+	}
+
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*rps)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rps * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// RateLimit ограничивает частоту запросов одного клиента по алгоритму token bucket
+func RateLimit(next http.Handler) http.Handler {
+	return rateLimited(next, false)
+}
+
+// AuthRateLimit ограничивает регистрации и входы с одного IP-адреса отдельным, более строгим
+// лимитом: каждая проверка пароля - 100 000 итераций PBKDF2
+func AuthRateLimit(next http.Handler) http.Handler {
+	return rateLimited(next, true)
+}
+
+func rateLimited(next http.Handler, authRoute bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limitsMu.RLock()
+		rl, rps, burst := limiter, currentLimits.RateLimitRPS, currentLimits.RateLimitBurst
+		if authRoute {
+			rl, rps, burst = authLimiter, currentLimits.AuthRateLimitRPS, currentLimits.AuthRateLimitBurst
+		}
+		limitsMu.RUnlock()
+
+		if rps > 0 {
+			ok, retryAfter := rl.allow(clientID(r), rps, burst, time.Now())
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				api.SendErrorResponse(w, r, http.StatusTooManyRequests, api.CodeRateLimited, "Rate limit exceeded")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
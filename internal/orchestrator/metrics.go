@@ -0,0 +1,121 @@
+package orchestrator
+
+import (
+	"calculator-service/internal/metrics"
+	"calculator-service/internal/types"
+	"net/http"
+	"time"
+)
+
+const (
+	resultAccepted    = "accepted"
+	resultInvalidBody = "invalid_body"
+	resultUnknownTask = "unknown_task"
+	resultFailed      = "failed"
+)
+
+var (
+	registry = metrics.NewRegistry()
+
+	dispatchLatency = registry.NewHistogramVec("calc_task_dispatch_latency_seconds",
+		"Time a ready task waited in the queue before an agent picked it up.",
+		metrics.DefaultBuckets, "operation")
+
+	taskResultsTotal = registry.NewCounterVec("calc_task_results_total",
+		"Task results submitted by agents, by outcome.", "outcome")
+
+	agentRequests = registry.NewCounterVec("calc_agent_requests_total",
+		"Task requests from agents by negotiated protocol version.", "protocol_version")
+
+	cacheLookups = registry.NewCounterVec("calc_expression_cache_total",
+		"Expression submissions by result cache outcome: hit, shared with an in-flight expression or miss.", "outcome")
+
+	webhookDeliveries = registry.NewCounterVec("calc_webhook_attempts_total",
+		"Webhook delivery attempts by resulting delivery status: delivered, pending retry or failed.", "status")
+
+	leasesExpired = registry.NewCounterVec("calc_task_leases_expired_total",
+		"Tasks returned to the queue because the agent did not submit a result before the lease expired, by operation.", "operation")
+
+	operationsPlanned = registry.NewCounterVec("calc_planned_operations_total",
+		"Operations of submitted expressions by source: computed by a new task, reused from an in-flight task or from the cache.", "source")
+)
+
+func init() {
+	registry.NewGaugeFunc("calc_expressions", "Expressions known to the orchestrator, by status.", "status", expressionsByStatus)
+	registry.NewGaugeFunc("calc_expression_cache_entries", "Results stored in the expression cache.", "", cacheEntries)
+	registry.NewGaugeFunc("calc_operation_reuse_ratio", "Share of planned operations served by reused subexpressions.", "", operationReuseRatio)
+	registry.NewGaugeFunc("calc_task_queue_depth", "Tasks that are ready, leased to an agent or blocked on dependencies.", "state", taskQueueDepth)
+	registry.NewGaugeFunc("calc_tasks_unschedulable", "Queued tasks that no online agent has the capabilities to run, by operation.", "operation", unschedulableTasks)
+}
+
+func HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	registry.Handler().ServeHTTP(w, r)
+}
+
+func expressionsByStatus() map[string]float64 {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	counts := map[string]float64{
+		types.StatusProcessing: 0,
+		types.StatusCompleted:  0,
+		types.StatusError:      0,
+	}
+	for _, expr := range expressions {
+		counts[expr.Status]++
+	}
+	return counts
+}
+
+func taskQueueDepth() map[string]float64 {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	depth := map[string]float64{"ready": 0, "leased": 0, "blocked": 0}
+	for id := range tasks {
+		timing, ok := taskTimings[id]
+		if !ok {
+			continue
+		}
+		if _, ready := taskReadyAt(id, timing); ready {
+			depth["ready"]++
+		} else {
+			depth["blocked"]++
+		}
+	}
+	for _, timing := range taskTimings {
+		if !timing.dispatchedAt.IsZero() && timing.finishedAt.IsZero() {
+			depth["leased"]++
+		}
+	}
+	return depth
+}
+
+func unschedulableTasks() map[string]float64 {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	now := time.Now()
+	counts := make(map[string]float64)
+	for _, task := range tasks {
+		if unschedulable(task, now) {
+			counts[task.Operation]++
+		}
+	}
+	return counts
+}
+
+func cacheEntries() map[string]float64 {
+	mu.RLock()
+	defer mu.RUnlock()
+	return map[string]float64{"": float64(results.len())}
+}
+
+func operationReuseRatio() map[string]float64 {
+	computed := operationsPlanned.Value(operationsComputed)
+	reused := operationsPlanned.Value(operationsInFlight) + operationsPlanned.Value(operationsCached)
+	if computed+reused == 0 {
+		return map[string]float64{"": 0}
+	}
+	return map[string]float64{"": reused / (computed + reused)}
+}
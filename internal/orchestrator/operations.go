@@ -0,0 +1,168 @@
+package orchestrator
+
+import (
+	"calculator-service/internal/api"
+	"calculator-service/internal/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// OperationTimes - время выполнения арифметических операций агентом. Оркестратор передаёт его
+// в каждой выдаваемой задаче и использует для оценки времени вычисления выражения
+type OperationTimes struct {
+	Addition       time.Duration
+	Subtraction    time.Duration
+	Multiplication time.Duration
+	Division       time.Duration
+}
+
+func DefaultOperationTimes() OperationTimes {
+	return OperationTimes{
+		Addition:       time.Second,
+		Subtraction:    time.Second,
+		Multiplication: time.Second,
+		Division:       time.Second,
+	}
+}
+
+// LoadOperationTimes читает начальные значения из TIME_ADDITION_MS, TIME_SUBTRACTION_MS,
+// TIME_MULTIPLICATIONS_MS и TIME_DIVISIONS_MS
+func LoadOperationTimes(get func(key string) string) (OperationTimes, error) {
+	t := DefaultOperationTimes()
+	var errs []error
+
+	vars := []struct {
+		key string
+		dst *time.Duration
+	}{
+		{"TIME_ADDITION_MS", &t.Addition},
+		{"TIME_SUBTRACTION_MS", &t.Subtraction},
+		{"TIME_MULTIPLICATIONS_MS", &t.Multiplication},
+		{"TIME_DIVISIONS_MS", &t.Division},
+	}
+	for _, v := range vars {
+		raw := get(v.key)
+		if raw == "" {
+			continue
+		}
+		ms, err := strconv.Atoi(raw)
+		if err != nil || ms < 0 || ms > types.MaxOperationTimeMs {
+			errs = append(errs, fmt.Errorf("invalid %s: %q, expected a number of milliseconds from 0 to %d", v.key, raw, types.MaxOperationTimeMs))
+			continue
+		}
+		*v.dst = time.Duration(ms) * time.Millisecond
+	}
+	return t, errors.Join(errs...)
+}
+
+// For возвращает время выполнения операции
+func (t OperationTimes) For(operation string) time.Duration {
+	switch operation {
+	case "+":
+		return t.Addition
+	case "-":
+		return t.Subtraction
+	case "*":
+		return t.Multiplication
+	case "/":
+		return t.Division
+	}
+	return 0
+}
+
+var (
+	operationTimesMu      sync.RWMutex
+	currentOperationTimes = DefaultOperationTimes()
+)
+
+func SetOperationTimes(t OperationTimes) {
+	operationTimesMu.Lock()
+	defer operationTimesMu.Unlock()
+	currentOperationTimes = t
+}
+
+func getOperationTimes() OperationTimes {
+	operationTimesMu.RLock()
+	defer operationTimesMu.RUnlock()
+	return currentOperationTimes
+}
+
+func (t OperationTimes) view() types.OperationTimes {
+	return types.OperationTimes{
+		AdditionMs:       t.Addition.Milliseconds(),
+		SubtractionMs:    t.Subtraction.Milliseconds(),
+		MultiplicationMs: t.Multiplication.Milliseconds(),
+		DivisionMs:       t.Division.Milliseconds(),
+	}
+}
+
+func HandleGetOperationTimes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(getOperationTimes().view())
+}
+
+// HandleUpdateOperationTimes меняет время операций без перезапуска. Поля, которых нет в запросе,
+// сохраняют текущие значения. Новое время получат задачи, выданные агентам после изменения
+func HandleUpdateOperationTimes(w http.ResponseWriter, r *http.Request) {
+	var update struct {
+		AdditionMs       *int64 `json:"addition_ms"`
+		SubtractionMs    *int64 `json:"subtraction_ms"`
+		MultiplicationMs *int64 `json:"multiplication_ms"`
+		DivisionMs       *int64 `json:"division_ms"`
+	}
+	if !decodeRequest(w, r, getLimits().MaxBodyBytes, &update) {
+		return
+	}
+
+	var t OperationTimes
+	fields := []struct {
+		name  string
+		value *int64
+		dst   *time.Duration
+	}{
+		{"addition_ms", update.AdditionMs, &t.Addition},
+		{"subtraction_ms", update.SubtractionMs, &t.Subtraction},
+		{"multiplication_ms", update.MultiplicationMs, &t.Multiplication},
+		{"division_ms", update.DivisionMs, &t.Division},
+	}
+	for _, f := range fields {
+		if f.value == nil {
+			continue
+		}
+		if *f.value < 0 {
+			api.SendErrorResponse(w, r, http.StatusUnprocessableEntity, api.CodeInvalidParameter, f.name+" must not be negative")
+			return
+		}
+		if *f.value > types.MaxOperationTimeMs {
+			api.SendErrorResponse(w, r, http.StatusUnprocessableEntity, api.CodeInvalidParameter,
+				fmt.Sprintf("%s must not exceed %d", f.name, types.MaxOperationTimeMs))
+			return
+		}
+	}
+
+	operationTimesMu.Lock()
+	t = currentOperationTimes
+	for _, f := range fields {
+		if f.value != nil {
+			*f.dst = time.Duration(*f.value) * time.Millisecond
+		}
+	}
+	currentOperationTimes = t
+	operationTimesMu.Unlock()
+
+	view := t.view()
+	slog.InfoContext(r.Context(), "operation times updated",
+		"addition_ms", view.AdditionMs,
+		"subtraction_ms", view.SubtractionMs,
+		"multiplication_ms", view.MultiplicationMs,
+		"division_ms", view.DivisionMs)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(view)
+}
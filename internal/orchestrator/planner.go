@@ -0,0 +1,215 @@
+package orchestrator
+
+import (
+	"calculator-service/internal/calculator"
+	"calculator-service/internal/types"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	operationsComputed = "computed"
+	operationsInFlight = "in_flight"
+	operationsCached   = "cached"
+)
+
+// planNode - узел дерева выражения. key - канонический хеш поддерева:
+// одинаковые поддеревья разных выражений получают одинаковый ключ
+type planNode struct {
+	op          string
+	value       float64
+	isNum       bool
+	left, right *planNode
+	key         string
+	operations  int
+}
+
+// taskDeps - задачи, результаты которых подставляются в аргументы задачи
+type taskDeps struct {
+	arg1, arg2 string
+}
+
+func (d taskDeps) ids() []string {
+	var ids []string
+	for _, id := range []string{d.arg1, d.arg2} {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// buildPlanTree строит дерево выражения из RPN и вычисляет ключи поддеревьев
+func buildPlanTree(rpn []calculator.Token) *planNode {
+	var stack []*planNode
+	for _, token := range rpn {
+		switch token.Type {
+		case calculator.Number:
+			num, _ := strconv.ParseFloat(token.Value, 64)
+			stack = append(stack, &planNode{
+				value: num,
+				isNum: true,
+				key:   "n:" + strconv.FormatFloat(num, 'g', -1, 64),
+			})
+		case calculator.Operator:
+			right, left := stack[len(stack)-1], stack[len(stack)-2]
+			stack = stack[:len(stack)-2]
+			stack = append(stack, &planNode{
+				op:         token.Value,
+				left:       left,
+				right:      right,
+				key:        subtreeKey(token.Value, left.key, right.key),
+				operations: left.operations + right.operations + 1,
+			})
+		}
+	}
+	return stack[0]
+}
+
+// subtreeKey хеширует операцию с ключами операндов. Операнды + и * упорядочиваются,
+// поэтому a*b и b*a дают один ключ
+func subtreeKey(op, left, right string) string {
+	if (op == "+" || op == "*") && right < left {
+		left, right = right, left
+	}
+	sum := sha256.Sum256([]byte(op + "(" + left + "," + right + ")"))
+	return hex.EncodeToString(sum[:])
+}
+
+// taskPlanner разбивает выражение на задачи, переиспользуя уже вычисленные
+// и выполняющиеся поддеревья. Вызывается под mu.Lock()
+type taskPlanner struct {
+	expr    types.Expression
+	taskIDs []string
+	seen    map[string]bool
+	reused  int // операций, для которых не понадобились новые задачи
+}
+
+func newTaskPlanner(expr types.Expression) *taskPlanner {
+	return &taskPlanner{expr: expr, seen: make(map[string]bool)}
+}
+
+func (p *taskPlanner) plan(node *planNode) stackItem {
+	if node.isNum {
+		return stackItem{value: node.value, isNum: true}
+	}
+
+	if result, ok := cachedSubexpression(node.key); ok {
+		p.reuse(node, operationsCached)
+		return stackItem{value: result, isNum: true}
+	}
+
+	if taskID, ok := subtreeTasks[node.key]; ok {
+		p.share(taskID)
+		p.reuse(node, operationsInFlight)
+		return stackItem{taskID: taskID}
+	}
+
+	left := p.plan(node.left)
+	right := p.plan(node.right)
+
+	taskID := uuid.New().String()
+	task := types.Task{
+		ID:           taskID,
+		Operation:    node.op,
+		ExpressionID: p.expr.ID,
+		RequestID:    p.expr.RequestID,
+		Arg1:         left.value,
+		Arg2:         right.value,
+	}
+	if node.op == "*" || node.op == "/" {
+		task.Priority = 2
+	} else {
+		task.Priority = 1
+	}
+	if !left.isNum || !right.isNum {
+		dependsOnTask[taskID] = taskDeps{arg1: left.taskID, arg2: right.taskID}
+	}
+
+	tasks[taskID] = task
+	taskToExpression[taskID] = p.expr.ID
+	taskRefs[taskID] = 1
+	taskKeys[taskID] = node.key
+	subtreeTasks[node.key] = taskID
+	recordTaskCreated(taskID, p.expr.CreatedAt)
+
+	p.seen[taskID] = true
+	p.taskIDs = append(p.taskIDs, taskID)
+	operationsPlanned.Inc(operationsComputed)
+
+	return stackItem{taskID: taskID}
+}
+
+// share добавляет выражению выполняющуюся задачу вместе с задачами, результатов которых она
+// ещё ждёт. Иначе они освободились бы при отмене или ошибке выражения, которое их создало
+func (p *taskPlanner) share(taskID string) {
+	if p.seen[taskID] {
+		return
+	}
+	p.seen[taskID] = true
+	p.taskIDs = append(p.taskIDs, taskID)
+	taskRefs[taskID]++
+	taskWaiters[taskID] = append(taskWaiters[taskID], p.expr.ID)
+
+	for _, depID := range dependsOnTask[taskID].ids() {
+		p.share(depID)
+	}
+}
+
+func (p *taskPlanner) reuse(node *planNode, source string) {
+	p.reused += node.operations
+	operationsPlanned.Add(float64(node.operations), source)
+}
+
+// Функции ниже вызываются под mu.Lock()
+
+func cachedSubexpression(key string) (float64, bool) {
+	limits := getLimits()
+	if limits.CacheSize <= 0 || limits.CacheTTL <= 0 {
+		return 0, false
+	}
+	return results.get(key, limits.CacheTTL, time.Now())
+}
+
+// storeSubexpression кэширует результат задачи как результат её поддерева
+func storeSubexpression(taskID string, result float64) {
+	key, ok := taskKeys[taskID]
+	if !ok {
+		return
+	}
+	if limits := getLimits(); limits.CacheSize > 0 && limits.CacheTTL > 0 {
+		results.put(key, result, limits.CacheSize, time.Now())
+	}
+}
+
+// taskExpressionIDs - выражение, создавшее задачу, и выражения, которые её переиспользуют
+func taskExpressionIDs(taskID string) []string {
+	ids := []string{taskToExpression[taskID]}
+	return append(ids, taskWaiters[taskID]...)
+}
+
+// releaseTask удаляет задачу, когда её результат больше не нужен ни одному выражению
+func releaseTask(taskID string) {
+	taskRefs[taskID]--
+	if taskRefs[taskID] > 0 {
+		return
+	}
+
+	if key := taskKeys[taskID]; subtreeTasks[key] == taskID {
+		delete(subtreeTasks, key)
+	}
+	delete(taskKeys, taskID)
+	delete(taskRefs, taskID)
+	delete(taskWaiters, taskID)
+	delete(taskResults, taskID)
+	delete(taskToExpression, taskID)
+	delete(dependsOnTask, taskID)
+	delete(tasks, taskID)
+	delete(taskTimings, taskID)
+	delete(taskAgents, taskID)
+	delete(taskLeases, taskID)
+}
@@ -0,0 +1,30 @@
+package orchestrator
+
+import (
+	"calculator-service/internal/api"
+	"calculator-service/internal/types"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// negotiateProtocol выбирает версию протокола для запроса агента и сообщает её в X-Protocol-Version ответа.
+// При неподдерживаемой версии отправляет ошибку и возвращает false
+func negotiateProtocol(w http.ResponseWriter, r *http.Request) (int, bool) {
+	peer, err := types.ParseProtocolVersion(r.Header.Get(types.ProtocolVersionHeader))
+	if err != nil {
+		api.SendErrorResponse(w, r, http.StatusBadRequest, api.CodeUnsupportedProtocol, err.Error())
+		return 0, false
+	}
+
+	version, ok := types.NegotiateProtocol(peer)
+	if !ok {
+		api.SendErrorResponse(w, r, http.StatusBadRequest, api.CodeUnsupportedProtocol,
+			fmt.Sprintf("Protocol version %d is not supported, minimum is %d", peer, types.MinProtocolVersion))
+		return 0, false
+	}
+
+	agentRequests.Inc(strconv.Itoa(version))
+	w.Header().Set(types.ProtocolVersionHeader, strconv.Itoa(version))
+	return version, true
+}
@@ -0,0 +1,222 @@
+package orchestrator
+
+import (
+	"calculator-service/internal/types"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 1000
+
+	sortByCreatedAt   = "created_at"
+	sortByCompletedAt = "completed_at"
+)
+
+type expressionQuery struct {
+	ownerID       string
+	statuses      map[string]bool
+	createdAfter  time.Time
+	createdBefore time.Time
+	sortBy        string
+	desc          bool
+	limit         int
+	cursor        *expressionCursor
+}
+
+// Курсор указывает на последний элемент предыдущей страницы: ключ сортировки и ID.
+// Поле и направление сортировки должны совпадать с запросом следующей страницы
+type expressionCursor struct {
+	sortBy string
+	desc   bool
+	key    int64
+	id     string
+}
+
+func parseExpressionQuery(values url.Values) (expressionQuery, error) {
+	q := expressionQuery{
+		sortBy: sortByCreatedAt,
+		desc:   true,
+		limit:  defaultPageLimit,
+	}
+
+	if raw := values.Get("status"); raw != "" {
+		q.statuses = make(map[string]bool)
+		for _, s := range strings.Split(raw, ",") {
+			s = strings.ToUpper(strings.TrimSpace(s))
+			switch s {
+			case types.StatusProcessing, types.StatusCompleted, types.StatusError:
+				q.statuses[s] = true
+			default:
+				return q, fmt.Errorf("unknown status: %s", s)
+			}
+		}
+	}
+
+	var err error
+	if raw := values.Get("created_from"); raw != "" {
+		if q.createdAfter, err = time.Parse(time.RFC3339, raw); err != nil {
+			return q, errors.New("created_from must be an RFC 3339 timestamp")
+		}
+	}
+	if raw := values.Get("created_to"); raw != "" {
+		if q.createdBefore, err = time.Parse(time.RFC3339, raw); err != nil {
+			return q, errors.New("created_to must be an RFC 3339 timestamp")
+		}
+	}
+
+	if raw := values.Get("sort"); raw != "" {
+		if raw != sortByCreatedAt && raw != sortByCompletedAt {
+			return q, fmt.Errorf("unknown sort field: %s", raw)
+		}
+		q.sortBy = raw
+	}
+
+	switch values.Get("order") {
+	case "", "desc":
+	case "asc":
+		q.desc = false
+	default:
+		return q, errors.New("order must be asc or desc")
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		q.limit, err = strconv.Atoi(raw)
+		if err != nil || q.limit < 1 || q.limit > maxPageLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+	}
+
+	if raw := values.Get("cursor"); raw != "" {
+		c, err := decodeCursor(raw)
+		if err != nil || c.sortBy != q.sortBy || c.desc != q.desc {
+			return q, errors.New("invalid cursor")
+		}
+		q.cursor = &c
+	}
+
+	return q, nil
+}
+
+// matches отбирает выражения запроса. При сортировке по completed_at незавершённые выражения
+// не показываются: их ключ появится только после завершения, и курсор пропускал бы или
+// повторял их при обходе страниц
+func (q expressionQuery) matches(expr types.Expression) bool {
+	if expr.OwnerID != q.ownerID {
+		return false
+	}
+	if q.sortBy == sortByCompletedAt && expr.CompletedAt == nil {
+		return false
+	}
+	if q.statuses != nil && !q.statuses[expr.Status] {
+		return false
+	}
+	if !q.createdAfter.IsZero() && expr.CreatedAt.Before(q.createdAfter) {
+		return false
+	}
+	if !q.createdBefore.IsZero() && !expr.CreatedAt.Before(q.createdBefore) {
+		return false
+	}
+	return true
+}
+
+// sortKey вызывается только для выражений, прошедших matches, поэтому completed_at задан
+func (q expressionQuery) sortKey(expr types.Expression) int64 {
+	if q.sortBy == sortByCompletedAt {
+		return expr.CompletedAt.UnixNano()
+	}
+	return expr.CreatedAt.UnixNano()
+}
+
+func (q expressionQuery) less(a, b types.Expression) bool {
+	ka, kb := q.sortKey(a), q.sortKey(b)
+	if ka != kb {
+		if q.desc {
+			return ka > kb
+		}
+		return ka < kb
+	}
+	if q.desc {
+		return a.ID > b.ID
+	}
+	return a.ID < b.ID
+}
+
+func (q expressionQuery) afterCursor(expr types.Expression) bool {
+	if q.cursor == nil {
+		return true
+	}
+	key := q.sortKey(expr)
+	if key == q.cursor.key {
+		if q.desc {
+			return expr.ID < q.cursor.id
+		}
+		return expr.ID > q.cursor.id
+	}
+	if q.desc {
+		return key < q.cursor.key
+	}
+	return key > q.cursor.key
+}
+
+// apply фильтрует, сортирует и режет список выражений на страницу
+func (q expressionQuery) apply(all []types.Expression) ([]types.Expression, string) {
+	page := make([]types.Expression, 0)
+	for _, expr := range all {
+		if q.matches(expr) && q.afterCursor(expr) {
+			page = append(page, expr)
+		}
+	}
+
+	sort.Slice(page, func(i, j int) bool {
+		return q.less(page[i], page[j])
+	})
+
+	if len(page) <= q.limit {
+		return page, ""
+	}
+
+	page = page[:q.limit]
+	last := page[len(page)-1]
+	return page, encodeCursor(expressionCursor{
+		sortBy: q.sortBy,
+		desc:   q.desc,
+		key:    q.sortKey(last),
+		id:     last.ID,
+	})
+}
+
+func encodeCursor(c expressionCursor) string {
+	order := "asc"
+	if c.desc {
+		order = "desc"
+	}
+	raw := fmt.Sprintf("%s|%s|%d|%s", c.sortBy, order, c.key, c.id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (expressionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return expressionCursor{}, err
+	}
+
+	parts := strings.SplitN(string(raw), "|", 4)
+	if len(parts) != 4 || (parts[1] != "asc" && parts[1] != "desc") {
+		return expressionCursor{}, errors.New("malformed cursor")
+	}
+
+	key, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return expressionCursor{}, err
+	}
+
+	return expressionCursor{sortBy: parts[0], desc: parts[1] == "desc", key: key, id: parts[3]}, nil
+}
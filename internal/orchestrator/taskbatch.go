@@ -0,0 +1,122 @@
+package orchestrator
+
+import (
+	"calculator-service/internal/api"
+	"calculator-service/internal/logging"
+	"calculator-service/internal/types"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HandleGetTasks выдаёт агенту до ?max= готовых задач, которые он может выполнить, в порядке
+// приоритета. Контекст трассировки каждой задачи передаётся в её поле traceparent
+func HandleGetTasks(w http.ResponseWriter, r *http.Request) {
+	version, ok := negotiateBatchProtocol(w, r)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("max"))
+	if err != nil || limit < 1 || limit > types.MaxTaskBatch {
+		api.SendErrorResponse(w, r, http.StatusBadRequest, api.CodeInvalidParameter,
+			fmt.Sprintf("Invalid max: must be an integer between 1 and %d", types.MaxTaskBatch))
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	now := time.Now()
+	agent := trackAgent(r, version, now)
+	state := agents[agent]
+	requeueExpiredTasks(now)
+
+	batch := types.TaskBatch{Tasks: []types.Task{}}
+	for len(batch.Tasks) < limit {
+		id, task, ok := nextReadyTask(state)
+		if !ok {
+			break
+		}
+		task, traceparent := leaseTask(agent, id, task)
+		task.Traceparent = traceparent
+		batch.Tasks = append(batch.Tasks, task)
+	}
+
+	w.Header().Set(types.QueueDepthHeader, strconv.Itoa(readyTaskCount(state)))
+	if len(batch.Tasks) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batch)
+}
+
+// HandleSubmitTaskResults принимает результаты нескольких задач. Каждый результат принимается
+// отдельно: результат неизвестной задачи не мешает остальным, его статус возвращается в ответе
+func HandleSubmitTaskResults(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	version, ok := negotiateBatchProtocol(w, r)
+	if !ok {
+		return
+	}
+
+	var batch types.TaskResultBatch
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		slog.WarnContext(ctx, "invalid task results body", "error", err)
+		taskResultsTotal.Inc(resultInvalidBody)
+		api.SendErrorResponse(w, r, http.StatusBadRequest, api.CodeInvalidBody, "Invalid request body")
+		return
+	}
+	if len(batch.Results) == 0 || len(batch.Results) > types.MaxTaskBatch {
+		taskResultsTotal.Inc(resultInvalidBody)
+		api.SendErrorResponse(w, r, http.StatusBadRequest, api.CodeInvalidBody,
+			fmt.Sprintf("Request must contain from 1 to %d results", types.MaxTaskBatch))
+		return
+	}
+
+	response := types.TaskResultBatchResponse{Results: make([]types.TaskResultStatus, 0, len(batch.Results))}
+
+	mu.Lock()
+	trackAgent(r, version, time.Now())
+	for _, result := range batch.Results {
+		status := types.TaskResultStatus{ID: result.ID, Accepted: true}
+		if err := acceptTaskResult(resultContext(ctx, result.ID), result, result.Traceparent); err != nil {
+			status.Accepted, status.ErrorCode, status.Error = false, err.code, err.message
+		}
+		response.Results = append(response.Results, status)
+	}
+	mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// resultContext - контекст логов результата из пакета с request_id выражения, создавшего задачу.
+// У пакета свой X-Request-ID, а в одиночном запросе агент передаёт request_id задачи в заголовке.
+// Вызывается под mu.Lock()
+func resultContext(ctx context.Context, taskID string) context.Context {
+	if expr, ok := expressions[taskToExpression[taskID]]; ok && expr.RequestID != "" {
+		return logging.WithRequestID(ctx, expr.RequestID)
+	}
+	return ctx
+}
+
+// negotiateBatchProtocol - как negotiateProtocol, но пакетные запросы требуют версии 4
+func negotiateBatchProtocol(w http.ResponseWriter, r *http.Request) (int, bool) {
+	version, ok := negotiateProtocol(w, r)
+	if !ok {
+		return 0, false
+	}
+	if version < types.ProtocolV4 {
+		api.SendErrorResponse(w, r, http.StatusBadRequest, api.CodeUnsupportedProtocol,
+			fmt.Sprintf("Batch task endpoints require protocol version %d", types.ProtocolV4))
+		return 0, false
+	}
+	return version, true
+}
@@ -0,0 +1,114 @@
+package orchestrator
+
+import (
+	"calculator-service/internal/types"
+	"time"
+)
+
+type taskTiming struct {
+	createdAt    time.Time
+	readyAt      time.Time
+	dispatchedAt time.Time
+	finishedAt   time.Time
+}
+
+// Все функции ниже вызываются под mu.Lock()
+
+func recordTaskCreated(taskID string, now time.Time) {
+	taskTimings[taskID] = &taskTiming{createdAt: now}
+}
+
+// Задача готова к выполнению, когда создана и завершились задачи, от которых она зависит
+func taskReadyAt(taskID string, timing *taskTiming) (time.Time, bool) {
+	readyAt := timing.createdAt
+	for _, depID := range dependsOnTask[taskID].ids() {
+		dep, ok := taskTimings[depID]
+		if !ok || dep.finishedAt.IsZero() {
+			return time.Time{}, false
+		}
+		if dep.finishedAt.After(readyAt) {
+			readyAt = dep.finishedAt
+		}
+	}
+	return readyAt, true
+}
+
+// Вызывается до удаления задачи из dependsOnTask
+func recordTaskDispatched(taskID string, now time.Time) {
+	timing, ok := taskTimings[taskID]
+	if !ok {
+		return
+	}
+
+	timing.readyAt, _ = taskReadyAt(taskID, timing)
+	timing.dispatchedAt = now
+	dispatchLatency.Observe(now.Sub(timing.readyAt).Seconds(), tasks[taskID].Operation)
+
+	for _, exprID := range taskExpressionIDs(taskID) {
+		if expr, ok := expressions[exprID]; ok && expr.StartedAt == nil {
+			startedAt := now
+			expr.StartedAt = &startedAt
+			expressions[exprID] = expr
+		}
+	}
+}
+
+func recordTaskFinished(taskID string, now time.Time) {
+	if timing, ok := taskTimings[taskID]; ok && timing.finishedAt.IsZero() {
+		timing.finishedAt = now
+	}
+}
+
+// expressionMetrics считает время ожидания в очереди и вычисления по задачам выражения.
+// Для незавершённых выражений и задач длительности считаются до момента now
+func expressionMetrics(expr types.Expression, taskIDs []string, now time.Time) *types.ExpressionMetrics {
+	end := now
+	if expr.CompletedAt != nil {
+		end = *expr.CompletedAt
+	}
+
+	metrics := &types.ExpressionMetrics{
+		WallTimeMs:       end.Sub(expr.CreatedAt).Milliseconds(),
+		Tasks:            len(taskIDs),
+		ReusedOperations: reusedOperations[expr.ID],
+	}
+
+	var queueWait, compute time.Duration
+	for _, taskID := range taskIDs {
+		timing, ok := taskTimings[taskID]
+		if !ok {
+			continue
+		}
+
+		if timing.dispatchedAt.IsZero() {
+			if readyAt, ready := taskReadyAt(taskID, timing); ready {
+				queueWait += end.Sub(readyAt)
+			}
+			continue
+		}
+
+		queueWait += timing.dispatchedAt.Sub(timing.readyAt)
+		if timing.finishedAt.IsZero() {
+			compute += end.Sub(timing.dispatchedAt)
+		} else {
+			compute += timing.finishedAt.Sub(timing.dispatchedAt)
+			metrics.TasksCompleted++
+		}
+	}
+
+	metrics.QueueWaitMs = queueWait.Milliseconds()
+	metrics.ComputeTimeMs = compute.Milliseconds()
+	return metrics
+}
+
+// withLiveMetrics дополняет выполняющееся выражение текущими метриками и операциями,
+// которые не может выполнить ни один подключённый агент
+func withLiveMetrics(expr types.Expression, now time.Time) types.Expression {
+	if expr.Metrics == nil {
+		expr.Metrics = expressionMetrics(expr, expressionTasks[expr.ID], now)
+	}
+	if expr.Status == types.StatusProcessing {
+		expr.Unschedulable = unschedulableOperations(expressionTasks[expr.ID], now)
+	}
+	return expr
+}
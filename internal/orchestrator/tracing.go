@@ -0,0 +1,74 @@
+package orchestrator
+
+import (
+	"calculator-service/internal/tracing"
+	"calculator-service/internal/types"
+	"net/http"
+)
+
+// Все функции ниже вызываются под mu.Lock()
+
+func startExpressionSpan(r *http.Request, expr types.Expression) *tracing.Span {
+	parent, _ := tracing.ParseTraceparent(r.Header.Get(tracing.TraceparentHeader))
+
+	span := tracing.StartAt("expression", parent, expr.CreatedAt)
+	span.SetAttr("expression_id", expr.ID)
+	span.SetAttr("expression", expr.Original)
+	span.SetAttr("request_id", expr.RequestID)
+	return span
+}
+
+func finishExpressionSpan(expr types.Expression) {
+	span, ok := expressionSpans[expr.ID]
+	if !ok {
+		return
+	}
+	delete(expressionSpans, expr.ID)
+
+	span.SetAttr("status", expr.Status)
+	if expr.Status == types.StatusCompleted {
+		span.SetAttr("result", expr.Result)
+	}
+	if expr.Metrics != nil {
+		span.SetAttr("tasks", expr.Metrics.Tasks)
+	}
+	span.EndAt(*expr.CompletedAt)
+}
+
+// traceDispatch записывает ожидание задачи в очереди как дочерний спан выражения
+// и возвращает его контекст для агента в формате traceparent
+func traceDispatch(id string, task types.Task) string {
+	var parent tracing.SpanContext
+	if root, ok := expressionSpans[task.ExpressionID]; ok {
+		parent = root.Context()
+	}
+
+	timing, ok := taskTimings[id]
+	if !ok {
+		return ""
+	}
+
+	span := tracing.StartAt("task.dispatch", parent, timing.readyAt)
+	span.SetAttr("expression_id", task.ExpressionID)
+	span.SetAttr("task_id", id)
+	span.SetAttr("operation", task.Operation)
+	span.EndAt(timing.dispatchedAt)
+
+	return span.Context().Traceparent()
+}
+
+// startResultSpan продолжает трассу агента, если он передал traceparent
+func startResultSpan(traceparent, taskID string) *tracing.Span {
+	parent, ok := tracing.ParseTraceparent(traceparent)
+	if !ok {
+		if exprID, exists := taskToExpression[taskID]; exists {
+			if root, exists := expressionSpans[exprID]; exists {
+				parent = root.Context()
+			}
+		}
+	}
+
+	span := tracing.Start("task.result", parent)
+	span.SetAttr("task_id", taskID)
+	return span
+}
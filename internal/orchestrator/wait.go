@@ -0,0 +1,102 @@
+package orchestrator
+
+import (
+	"calculator-service/internal/types"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseWait читает время ожидания результата из ?wait=30s или заголовка Prefer: wait=30.
+// Значение ограничивается Limits.MaxWait
+func parseWait(r *http.Request) (time.Duration, bool, error) {
+	var wait time.Duration
+	fromPrefer := false
+
+	if raw := r.URL.Query().Get("wait"); raw != "" {
+		d, err := parseWaitValue(raw)
+		if err != nil {
+			return 0, false, errors.New("wait must be a duration like 30s or a number of seconds")
+		}
+		wait = d
+	} else if raw, ok := preferWait(r.Header.Values("Prefer")); ok {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds < 0 {
+			return 0, false, errors.New("Prefer: wait must be a number of seconds")
+		}
+		wait = time.Duration(seconds) * time.Second
+		fromPrefer = true
+	}
+
+	if limit := getLimits().MaxWait; limit > 0 && wait > limit {
+		wait = limit
+	}
+	return wait, fromPrefer, nil
+}
+
+func parseWaitValue(raw string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(raw); err == nil {
+		if seconds < 0 {
+			return 0, errors.New("negative wait")
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return 0, errors.New("invalid wait")
+	}
+	return d, nil
+}
+
+// preferWait ищет предпочтение wait в заголовках Prefer (RFC 7240)
+func preferWait(values []string) (string, bool) {
+	for _, value := range values {
+		for _, pref := range strings.Split(value, ",") {
+			name, val, found := strings.Cut(strings.TrimSpace(pref), "=")
+			if found && strings.EqualFold(strings.TrimSpace(name), "wait") {
+				return strings.Trim(strings.TrimSpace(val), `"`), true
+			}
+		}
+	}
+	return "", false
+}
+
+// waitForExpression ждёт завершения выражения не дольше timeout.
+// Возвращает false, если выражение ещё выполняется
+func waitForExpression(ctx context.Context, id string, timeout time.Duration) (types.Expression, bool) {
+	mu.RLock()
+	done, pending := expressionDone[id]
+	mu.RUnlock()
+
+	if pending {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-done:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	expr, exists := expressions[id]
+	if !exists || expr.Status == types.StatusProcessing {
+		return expr, false
+	}
+	return withLiveMetrics(expr, time.Now()), true
+}
+
+// notifyExpressionDone будит ожидающих завершения выражения. Вызывается под mu.Lock()
+func notifyExpressionDone(id string) {
+	if done, ok := expressionDone[id]; ok {
+		close(done)
+		delete(expressionDone, id)
+	}
+}
@@ -0,0 +1,289 @@
+package orchestrator
+
+import (
+	"bytes"
+	"calculator-service/internal/types"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+
+	webhookPending   = "pending"
+	webhookDelivered = "delivered"
+	webhookFailed    = "failed"
+)
+
+// WebhookConfig - настройки доставки результатов на callback_url
+type WebhookConfig struct {
+	Secret         string
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Timeout        time.Duration
+	// AllowedNetworks - внутренние сети, в которые разрешена доставка. Остальные локальные,
+	// частные и служебные адреса запрещены, чтобы callback_url не открывал доступ к внутренней сети
+	AllowedNetworks []*net.IPNet
+}
+
+func DefaultWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Timeout:        10 * time.Second,
+	}
+}
+
+// LoadWebhookConfig читает WEBHOOK_SECRET, WEBHOOK_MAX_ATTEMPTS, WEBHOOK_INITIAL_BACKOFF,
+// WEBHOOK_MAX_BACKOFF, WEBHOOK_TIMEOUT и WEBHOOK_ALLOWED_NETWORKS. Без WEBHOOK_SECRET
+// callback_url не принимается
+func LoadWebhookConfig(get func(key string) string) (WebhookConfig, error) {
+	c := DefaultWebhookConfig()
+	c.Secret = get("WEBHOOK_SECRET")
+	var errs []error
+
+	if raw := get("WEBHOOK_MAX_ATTEMPTS"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			errs = append(errs, fmt.Errorf("invalid WEBHOOK_MAX_ATTEMPTS: %q, expected a positive integer", raw))
+		} else {
+			c.MaxAttempts = n
+		}
+	}
+
+	durations := []struct {
+		key string
+		dst *time.Duration
+	}{
+		{"WEBHOOK_INITIAL_BACKOFF", &c.InitialBackoff},
+		{"WEBHOOK_MAX_BACKOFF", &c.MaxBackoff},
+		{"WEBHOOK_TIMEOUT", &c.Timeout},
+	}
+	for _, v := range durations {
+		raw := get(v.key)
+		if raw == "" {
+			continue
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("invalid %s: %q, expected a positive duration such as 1s", v.key, raw))
+			continue
+		}
+		*v.dst = d
+	}
+
+	if raw := get("WEBHOOK_ALLOWED_NETWORKS"); raw != "" {
+		for _, cidr := range strings.Split(raw, ",") {
+			_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid WEBHOOK_ALLOWED_NETWORKS: %q, expected comma-separated CIDRs such as 10.0.0.0/8", raw))
+				break
+			}
+			c.AllowedNetworks = append(c.AllowedNetworks, network)
+		}
+	}
+
+	return c, errors.Join(errs...)
+}
+
+var (
+	webhookMu     sync.RWMutex
+	webhookConfig = DefaultWebhookConfig()
+)
+
+func SetWebhookConfig(c WebhookConfig) {
+	webhookMu.Lock()
+	defer webhookMu.Unlock()
+	webhookConfig = c
+}
+
+func getWebhookConfig() WebhookConfig {
+	webhookMu.RLock()
+	defer webhookMu.RUnlock()
+	return webhookConfig
+}
+
+// validateCallbackURL принимает только абсолютные http(s) адреса, все IP которых разрешены.
+// Адрес может смениться после проверки, поэтому deliverWebhook проверяет его ещё раз при соединении
+func validateCallbackURL(ctx context.Context, raw string) error {
+	cfg := getWebhookConfig()
+	if cfg.Secret == "" {
+		return errors.New("webhooks are disabled on this server")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("callback_url must be an absolute http or https URL")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("callback_url host %q cannot be resolved", u.Hostname())
+	}
+	for _, addr := range addrs {
+		if !cfg.allowedIP(addr.IP) {
+			return fmt.Errorf("callback_url host %q resolves to a local or private address", u.Hostname())
+		}
+	}
+	return nil
+}
+
+// allowedIP запрещает адреса loopback, частных, link-local и multicast сетей и unspecified,
+// кроме сетей из AllowedNetworks
+func (c WebhookConfig) allowedIP(ip net.IP) bool {
+	for _, network := range c.AllowedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// webhookClient соединяется только с разрешёнными адресами, в том числе при редиректах
+// и если DNS вернул другой адрес, чем при проверке callback_url
+func webhookClient(cfg WebhookConfig) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: cfg.Timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !cfg.allowedIP(ip) {
+				return fmt.Errorf("callback address %s is not allowed", host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+	}
+}
+
+// SignWebhook подписывает тело запроса: HMAC-SHA256 от "<timestamp>.<body>"
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// scheduleWebhook отправляет завершённое выражение на его callback_url. Вызывается под mu.Lock()
+func scheduleWebhook(expr types.Expression) {
+	if expr.Callback == nil || expr.Callback.Status != webhookPending {
+		return
+	}
+
+	payload, err := json.Marshal(expr)
+	if err != nil {
+		slog.Error("webhook payload encoding failed", "expression_id", expr.ID, "error", err)
+		return
+	}
+	go deliverWebhook(expr.ID, expr.Callback.URL, payload, getWebhookConfig())
+}
+
+func deliverWebhook(exprID, callbackURL string, payload []byte, cfg WebhookConfig) {
+	client := webhookClient(cfg)
+	defer client.CloseIdleConnections()
+	backoff := cfg.InitialBackoff
+
+	for attempt := 1; attempt <= cfg.MaxAttempts; attempt++ {
+		statusCode, err := postWebhook(client, callbackURL, payload, cfg.Secret)
+
+		status := webhookPending
+		switch {
+		case err == nil && statusCode >= 200 && statusCode < 300:
+			status = webhookDelivered
+		case attempt == cfg.MaxAttempts || (err == nil && !retryableStatus(statusCode)):
+			status = webhookFailed
+		}
+		if err == nil && status != webhookDelivered {
+			err = fmt.Errorf("callback responded with status %d", statusCode)
+		}
+
+		recordWebhookAttempt(exprID, attempt, statusCode, err, status)
+		if status != webhookPending {
+			return
+		}
+
+		slog.Warn("webhook delivery failed, retrying",
+			"expression_id", exprID, "attempt", attempt, "backoff", backoff, "error", err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, cfg.MaxBackoff)
+	}
+}
+
+func postWebhook(client *http.Client, callbackURL string, payload []byte, secret string) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, callbackURL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(secret, timestamp, payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// Ошибки клиента, кроме таймаута и превышения частоты, повторять бессмысленно
+func retryableStatus(code int) bool {
+	return code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+}
+
+func recordWebhookAttempt(exprID string, attempt, statusCode int, err error, status string) {
+	webhookDeliveries.Inc(status)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expr, ok := expressions[exprID]
+	if !ok || expr.Callback == nil {
+		return
+	}
+
+	now := time.Now()
+	delivery := *expr.Callback
+	delivery.Status = status
+	delivery.Attempts = attempt
+	delivery.LastAttemptAt = &now
+	delivery.LastStatusCode = statusCode
+	delivery.LastError = ""
+	if err != nil {
+		delivery.LastError = err.Error()
+	}
+	expr.Callback = &delivery
+	expressions[exprID] = expr
+
+	if status == webhookFailed {
+		slog.Error("webhook delivery failed", "expression_id", exprID, "attempts", attempt, "error", delivery.LastError)
+	} else if status == webhookDelivered {
+		slog.Info("webhook delivered", "expression_id", exprID, "attempts", attempt)
+	}
+}
@@ -0,0 +1,204 @@
+// Package repl - интерактивное вычисление выражений локальным калькулятором
+// с переменными и просмотром разбора выражения на задачи
+package repl
+
+import (
+	"calculator-service/internal/calculator"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrQuit возвращается командой :quit
+var ErrQuit = errors.New("quit")
+
+// lastResult - переменная с результатом последнего вычисления
+const lastResult = "ans"
+
+const help = `Выражения:      2+2*2
+Присваивание:   x = 3*4          (переменные живут до конца сессии, ans - последний результат)
+Команды:
+  :rpn [выражение]   обратная польская запись
+  :ast [выражение]   дерево операций и задачи, на которые его разобьёт оркестратор
+  :vars              переменные
+  :history           история ввода
+  :help              эта справка
+  :quit              выход (или Ctrl+D)
+Без выражения :rpn и :ast показывают последнее вычисленное выражение.`
+
+var commands = []string{":ast", ":help", ":history", ":quit", ":rpn", ":vars"}
+
+type Session struct {
+	vars    map[string]float64
+	history []string
+	last    string
+}
+
+func NewSession() *Session {
+	return &Session{vars: make(map[string]float64)}
+}
+
+// Execute выполняет строку: выражение, присваивание или команду, и возвращает текст для вывода
+func (s *Session) Execute(line string) (string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", nil
+	}
+	s.history = append(s.history, line)
+
+	if strings.HasPrefix(line, ":") {
+		return s.command(line)
+	}
+
+	if name, expr, ok := strings.Cut(line, "="); ok {
+		name = strings.TrimSpace(name)
+		if !isIdent(name) {
+			return "", fmt.Errorf("invalid variable name: %q", name)
+		}
+		value, err := s.eval(expr)
+		if err != nil {
+			return "", err
+		}
+		s.vars[name] = value
+		return name + " = " + formatNumber(value), nil
+	}
+
+	value, err := s.eval(line)
+	if err != nil {
+		return "", err
+	}
+	return formatNumber(value), nil
+}
+
+func (s *Session) eval(expr string) (float64, error) {
+	calc := s.calculator()
+	value, err := calc.Calculate(strings.TrimSpace(expr))
+	if err != nil {
+		return 0, err
+	}
+	s.last = strings.TrimSpace(expr)
+	s.vars[lastResult] = value
+	return value, nil
+}
+
+func (s *Session) calculator() *calculator.Calculator {
+	calc := calculator.NewCalculator()
+	calc.Variables = s.vars
+	return calc
+}
+
+func (s *Session) command(line string) (string, error) {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case ":quit", ":q", ":exit":
+		return "", ErrQuit
+	case ":help":
+		return help, nil
+	case ":vars":
+		return s.listVars(), nil
+	case ":history":
+		return s.listHistory(), nil
+	case ":rpn", ":ast":
+		if arg == "" {
+			arg = s.last
+		}
+		if arg == "" {
+			return "", fmt.Errorf("%s needs an expression", name)
+		}
+		rpn, err := s.rpn(arg)
+		if err != nil {
+			return "", err
+		}
+		if name == ":rpn" {
+			return formatRPN(rpn), nil
+		}
+		return formatTree(rpn)
+	default:
+		return "", fmt.Errorf("unknown command %s, see :help", name)
+	}
+}
+
+func (s *Session) rpn(expr string) ([]calculator.Token, error) {
+	calc := s.calculator()
+	if err := calc.Tokenize(expr); err != nil {
+		return nil, err
+	}
+	return calc.ToRPN()
+}
+
+func (s *Session) listVars() string {
+	if len(s.vars) == 0 {
+		return "no variables"
+	}
+	names := make([]string, 0, len(s.vars))
+	for name := range s.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(name + " = " + formatNumber(s.vars[name]))
+	}
+	return b.String()
+}
+
+func (s *Session) listHistory() string {
+	var b strings.Builder
+	for i, line := range s.history {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "%4d  %s", i+1, line)
+	}
+	return b.String()
+}
+
+// History возвращает введённые строки в порядке ввода
+func (s *Session) History() []string {
+	return s.history
+}
+
+// Complete возвращает варианты продолжения слова word: команды для слов с :, иначе имена переменных
+func (s *Session) Complete(word string) []string {
+	var candidates []string
+	if strings.HasPrefix(word, ":") {
+		candidates = commands
+	} else {
+		for name := range s.vars {
+			candidates = append(candidates, name)
+		}
+	}
+
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, word) {
+			matches = append(matches, c)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func isIdent(name string) bool {
+	if name == "" || !calculator.IsIdentStart(name[0]) {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if !calculator.IsIdentPart(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
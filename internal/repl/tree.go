@@ -0,0 +1,104 @@
+package repl
+
+import (
+	"calculator-service/internal/calculator"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type node struct {
+	token       calculator.Token
+	left, right *node
+	key         string
+	task        int // номер задачи оркестратора, 0 для чисел
+	stage       int // шаг, на котором задача может выполниться: 1 + шаг самого позднего операнда
+	reused      bool
+}
+
+func formatRPN(rpn []calculator.Token) string {
+	values := make([]string, len(rpn))
+	for i, token := range rpn {
+		values[i] = token.Value
+	}
+	return strings.Join(values, " ")
+}
+
+// formatTree показывает дерево операций так же, как его разбивает на задачи оркестратор:
+// каждая операция - отдельная задача, одинаковые подвыражения вычисляются один раз,
+// а задачи одного шага могут выполняться параллельно
+func formatTree(rpn []calculator.Token) (string, error) {
+	var stack []*node
+	for _, token := range rpn {
+		switch token.Type {
+		case calculator.Number:
+			value, err := strconv.ParseFloat(token.Value, 64)
+			if err != nil {
+				return "", fmt.Errorf("invalid number: %s", token.Value)
+			}
+			stack = append(stack, &node{token: token, key: formatNumber(value)})
+		case calculator.Operator:
+			if len(stack) < 2 {
+				return "", errors.New("invalid expression")
+			}
+			n := &node{token: token, left: stack[len(stack)-2], right: stack[len(stack)-1]}
+			stack = append(stack[:len(stack)-2], n)
+		}
+	}
+	if len(stack) != 1 {
+		return "", errors.New("invalid expression")
+	}
+	root := stack[0]
+
+	tasks := make(map[string]*node)
+	stages := number(root, tasks)
+
+	var b strings.Builder
+	write(&b, root, "", "")
+	if len(tasks) == 0 {
+		b.WriteString("no tasks: the expression is a number")
+	} else {
+		fmt.Fprintf(&b, "tasks: %d, stages: %d", len(tasks), stages)
+	}
+	return b.String(), nil
+}
+
+// number нумерует задачи в порядке обхода операндов и возвращает шаг узла
+func number(n *node, tasks map[string]*node) int {
+	if n.left == nil {
+		return 0
+	}
+	stage := 1 + max(number(n.left, tasks), number(n.right, tasks))
+
+	left, right := n.left.key, n.right.key
+	if (n.token.Value == "+" || n.token.Value == "*") && left > right {
+		left, right = right, left
+	}
+	n.key = n.token.Value + "(" + left + "," + right + ")"
+
+	if same, ok := tasks[n.key]; ok {
+		n.task, n.stage, n.reused = same.task, same.stage, true
+		return same.stage
+	}
+	n.task, n.stage = len(tasks)+1, stage
+	tasks[n.key] = n
+	return stage
+}
+
+func write(b *strings.Builder, n *node, prefix, childPrefix string) {
+	b.WriteString(prefix + n.token.Value)
+	switch {
+	case n.reused:
+		fmt.Fprintf(b, "    reuses task %d", n.task)
+	case n.task > 0:
+		fmt.Fprintf(b, "    task %d, stage %d", n.task, n.stage)
+	}
+	b.WriteByte('\n')
+
+	if n.left == nil || n.reused {
+		return
+	}
+	write(b, n.left, childPrefix+"├── ", childPrefix+"│   ")
+	write(b, n.right, childPrefix+"└── ", childPrefix+"    ")
+}
@@ -0,0 +1,216 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const TraceparentHeader = "traceparent"
+
+// SpanContext - идентификаторы трассы и спана, которые передаются между сервисами
+type SpanContext struct {
+	TraceID string
+	SpanID  string
+}
+
+func (sc SpanContext) IsValid() bool {
+	return len(sc.TraceID) == 32 && len(sc.SpanID) == 16
+}
+
+// Traceparent форматирует контекст в заголовок W3C Trace Context
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", sc.TraceID, sc.SpanID)
+}
+
+func ParseTraceparent(header string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return SpanContext{}, false
+	}
+
+	sc := SpanContext{TraceID: parts[1], SpanID: parts[2]}
+	if !sc.IsValid() || !isHex(sc.TraceID) || !isHex(sc.SpanID) {
+		return SpanContext{}, false
+	}
+	return sc, true
+}
+
+type Span struct {
+	Name         string         `json:"name"`
+	Service      string         `json:"service"`
+	TraceID      string         `json:"trace_id"`
+	SpanID       string         `json:"span_id"`
+	ParentSpanID string         `json:"parent_span_id,omitempty"`
+	StartTime    time.Time      `json:"start_time"`
+	EndTime      time.Time      `json:"end_time"`
+	DurationMs   float64        `json:"duration_ms"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+
+	tracer *Tracer
+	once   sync.Once
+	mu     sync.Mutex
+}
+
+func (s *Span) Context() SpanContext {
+	return SpanContext{TraceID: s.TraceID, SpanID: s.SpanID}
+}
+
+func (s *Span) SetAttr(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]any)
+	}
+	s.Attributes[key] = value
+}
+
+func (s *Span) End() {
+	s.EndAt(time.Now())
+}
+
+// EndAt завершает спан и отправляет его в экспортёр. Повторные вызовы игнорируются
+func (s *Span) EndAt(end time.Time) {
+	s.once.Do(func() {
+		s.mu.Lock()
+		s.EndTime = end
+		s.DurationMs = float64(end.Sub(s.StartTime).Microseconds()) / 1000
+		s.mu.Unlock()
+		s.tracer.export(s)
+	})
+}
+
+// Exporter получает завершённые спаны
+type Exporter interface {
+	Export(span *Span) error
+}
+
+// WriterExporter пишет спаны в формате JSON, по одному на строку
+type WriterExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{w: w}
+}
+
+func (e *WriterExporter) Export(span *Span) error {
+	span.mu.Lock()
+	data, err := json.Marshal(span)
+	span.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err = e.w.Write(append(data, '\n'))
+	return err
+}
+
+type Tracer struct {
+	service  string
+	exporter Exporter
+}
+
+// NewTracer создаёт трейсер. Без экспортёра спаны создаются, но никуда не пишутся
+func NewTracer(service string, exporter Exporter) *Tracer {
+	return &Tracer{service: service, exporter: exporter}
+}
+
+// StartAt начинает спан в момент start. Без валидного родителя начинается новая трасса
+func (t *Tracer) StartAt(name string, parent SpanContext, start time.Time) *Span {
+	span := &Span{
+		Name:      name,
+		Service:   t.service,
+		SpanID:    randomHex(8),
+		StartTime: start,
+		tracer:    t,
+	}
+	if parent.IsValid() {
+		span.TraceID = parent.TraceID
+		span.ParentSpanID = parent.SpanID
+	} else {
+		span.TraceID = randomHex(16)
+	}
+	return span
+}
+
+func (t *Tracer) Start(name string, parent SpanContext) *Span {
+	return t.StartAt(name, parent, time.Now())
+}
+
+func (t *Tracer) export(span *Span) {
+	if t.exporter == nil {
+		return
+	}
+	t.exporter.Export(span)
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultTracer = NewTracer("", nil)
+)
+
+func SetDefault(t *Tracer) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultTracer = t
+}
+
+func Default() *Tracer {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultTracer
+}
+
+func Start(name string, parent SpanContext) *Span {
+	return Default().Start(name, parent)
+}
+
+func StartAt(name string, parent SpanContext, start time.Time) *Span {
+	return Default().StartAt(name, parent, start)
+}
+
+// Setup настраивает трейсер по умолчанию из TRACING_EXPORTER (none, stdout, file) и TRACING_FILE
+func Setup(service string) error {
+	switch exporter := os.Getenv("TRACING_EXPORTER"); exporter {
+	case "", "none":
+		SetDefault(NewTracer(service, nil))
+	case "stdout":
+		SetDefault(NewTracer(service, NewWriterExporter(os.Stdout)))
+	case "file":
+		path := os.Getenv("TRACING_FILE")
+		if path == "" {
+			path = "traces.jsonl"
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("open trace file: %w", err)
+		}
+		SetDefault(NewTracer(service, NewWriterExporter(f)))
+	default:
+		return fmt.Errorf("invalid TRACING_EXPORTER %q", exporter)
+	}
+	return nil
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,80 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Версии протокола обмена задачами между оркестратором и агентами.
+// Агент передаёт в заголовке X-Protocol-Version максимальную версию, которую понимает,
+// оркестратор отвечает версией, по которой работает запрос: меньшей из своей и версии агента.
+// Запросы без заголовка считаются запросами версии 1, так в одном кластере могут работать
+// агенты разных версий во время обновления
+const (
+	ProtocolVersionHeader = "X-Protocol-Version"
+	// AgentIDHeader - постоянный идентификатор агента, по нему оркестратор ведёт список агентов
+	AgentIDHeader = "X-Agent-ID"
+	// AgentCapacityHeader - число задач, которые агент выполняет одновременно (COMPUTING_POWER)
+	AgentCapacityHeader = "X-Agent-Capacity"
+	// QueueDepthHeader - число готовых к выдаче задач в очереди оркестратора после ответа агенту
+	QueueDepthHeader = "X-Queue-Depth"
+	// AgentCapabilitiesHeader - операции, которые умеет выполнять агент, через запятую
+	AgentCapabilitiesHeader = "X-Agent-Capabilities"
+
+	// ProtocolV1 - исходный протокол: агент возвращает только результат операции
+	ProtocolV1 = 1
+	// ProtocolV2 - агент может сообщить об ошибке вычисления в поле TaskResult.Error
+	ProtocolV2 = 2
+	// ProtocolV3 - оркестратор задаёт время операции в Task.OperationTime, агент выполняет задачу за это время
+	ProtocolV3 = 3
+	// ProtocolV4 - пакетная выдача задач и приём результатов на /internal/tasks
+	ProtocolV4 = 4
+
+	MinProtocolVersion = ProtocolV1
+	ProtocolVersion    = ProtocolV4
+
+	// MaxTaskBatch - сколько задач агент может получить или сдать одним пакетным запросом
+	MaxTaskBatch = 100
+)
+
+// ParseProtocolVersion разбирает значение заголовка X-Protocol-Version. Пустое значение - версия 1
+func ParseProtocolVersion(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ProtocolV1, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid protocol version %q", value)
+	}
+	return version, nil
+}
+
+// NegotiateProtocol выбирает версию для собеседника, поддерживающего версии до peer включительно.
+// Возвращает false, если собеседник не поддерживает даже минимальную версию
+func NegotiateProtocol(peer int) (int, bool) {
+	if peer < MinProtocolVersion {
+		return 0, false
+	}
+	return min(peer, ProtocolVersion), true
+}
+
+// BasicOperations - операции, которые выполняет агент, не сообщивший свои возможности
+var BasicOperations = []string{"+", "-", "*", "/"}
+
+// ParseCapabilities разбирает значение заголовка X-Agent-Capabilities: "+,-" -> ["+", "-"].
+// Пустые элементы и повторы отбрасываются
+func ParseCapabilities(value string) []string {
+	var capabilities []string
+	seen := make(map[string]bool)
+	for _, capability := range strings.Split(value, ",") {
+		capability = strings.TrimSpace(capability)
+		if capability == "" || seen[capability] {
+			continue
+		}
+		seen[capability] = true
+		capabilities = append(capabilities, capability)
+	}
+	return capabilities
+}
@@ -1,31 +1,238 @@
+// Package types - единая схема данных публичного API и протокола между оркестратором и агентами
 package types
 
+import "time"
+
+const (
+	StatusProcessing = "PROCESSING"
+	StatusCompleted  = "COMPLETED"
+	StatusError      = "ERROR"
+)
+
+const (
+	AgentOnline  = "online"
+	AgentOffline = "offline"
+)
+
+// OperationTimes - время выполнения операций агентом в миллисекундах
+type OperationTimes struct {
+	AdditionMs       int64 `json:"addition_ms"`
+	SubtractionMs    int64 `json:"subtraction_ms"`
+	MultiplicationMs int64 `json:"multiplication_ms"`
+	DivisionMs       int64 `json:"division_ms"`
+}
+
+// MaxOperationTimeMs - верхняя граница времени операции (сутки). Большие значения переполняют time.Duration
+const MaxOperationTimeMs = 24 * 60 * 60 * 1000
+
 type Task struct {
-	ID            string  `json:"id"`
-	Arg1          float64 `json:"arg1"`
-	Arg2          float64 `json:"arg2"`
-	Operation     string  `json:"operation"`
-	OperationTime int     `json:"operation_time"`
-	Priority      int     `json:"priority"`
-	DependsOn     string  `json:"depends_on,omitempty"`
+	ID        string  `json:"id"`
+	Arg1      float64 `json:"arg1"`
+	Arg2      float64 `json:"arg2"`
+	Operation string  `json:"operation"`
+	// OperationTime - время выполнения операции в миллисекундах. Агент учитывает его с версии протокола 3
+	OperationTime int    `json:"operation_time"`
+	Priority      int    `json:"priority"`
+	DependsOn     string `json:"depends_on,omitempty"`
+	ExpressionID  string `json:"expression_id,omitempty"`
+	RequestID     string `json:"request_id,omitempty"`
+	// Traceparent - контекст трассировки задачи при пакетной выдаче. Одиночная задача передаёт его в заголовке
+	Traceparent string `json:"traceparent,omitempty"`
 }
 
 type TaskResult struct {
 	ID     string  `json:"id"`
 	Result float64 `json:"result"`
+	// Error - причина, по которой агент не смог выполнить операцию. Только с версии протокола 2
+	Error string `json:"error,omitempty"`
+	// Traceparent - контекст трассировки вычисления при пакетной отправке результатов
+	Traceparent string `json:"traceparent,omitempty"`
+}
+
+// TaskBatch - задачи, выданные агенту одним запросом. С версии протокола 4
+type TaskBatch struct {
+	Tasks []Task `json:"tasks"`
+}
+
+// TaskResultBatch - результаты задач, которые агент отправляет одним запросом. С версии протокола 4
+type TaskResultBatch struct {
+	Results []TaskResult `json:"results"`
+}
+
+// TaskResultStatus - принят ли результат из пакета. Непринятый результат сопровождается кодом ошибки
+type TaskResultStatus struct {
+	ID        string `json:"id"`
+	Accepted  bool   `json:"accepted"`
+	ErrorCode string `json:"error_code,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type TaskResultBatchResponse struct {
+	Results []TaskResultStatus `json:"results"`
 }
 
 type Expression struct {
-	ID       string  `json:"id"`
-	Original string  `json:"expression"`
-	Status   string  `json:"status"`
-	Result   float64 `json:"result"`
+	ID          string             `json:"id"`
+	Original    string             `json:"expression"`
+	Status      string             `json:"status"`
+	Result      float64            `json:"result"`
+	CreatedAt   time.Time          `json:"created_at"`
+	StartedAt   *time.Time         `json:"started_at,omitempty"`
+	CompletedAt *time.Time         `json:"completed_at,omitempty"`
+	Metrics     *ExpressionMetrics `json:"metrics,omitempty"`
+	RequestID   string             `json:"request_id,omitempty"`
+	OwnerID     string             `json:"owner_id,omitempty"`
+	Cached      bool               `json:"cached,omitempty"`
+	Callback    *WebhookDelivery   `json:"callback,omitempty"`
+	Error       string             `json:"error,omitempty"`
+	// Unschedulable - операции задач выражения, которые не может выполнить ни один подключённый агент
+	Unschedulable []string `json:"unschedulable_operations,omitempty"`
+}
+
+// WebhookDelivery - состояние доставки результата на callback_url
+type WebhookDelivery struct {
+	URL            string     `json:"url"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+}
+
+// ExpressionMetrics - суммарные длительности по всем задачам выражения
+type ExpressionMetrics struct {
+	WallTimeMs    int64 `json:"wall_time_ms"`
+	QueueWaitMs   int64 `json:"queue_wait_ms"`
+	ComputeTimeMs int64 `json:"compute_time_ms"`
+	Tasks         int   `json:"tasks"`
+	// TasksCompleted - задачи, результат которых уже получен от агентов
+	TasksCompleted int `json:"tasks_completed"`
+	// ReusedOperations - операции, результат которых взят из других выражений или кэша
+	ReusedOperations int `json:"reused_operations"`
+}
+
+// Agent - агент, обращавшийся к оркестратору за задачами
+type Agent struct {
+	ID              string    `json:"id"`
+	Status          string    `json:"status"`
+	ProtocolVersion int       `json:"protocol_version"`
+	Capacity        int       `json:"capacity"`
+	Capabilities    []string  `json:"capabilities"`
+	FirstSeenAt     time.Time `json:"first_seen_at"`
+	LastSeenAt      time.Time `json:"last_seen_at"`
+	TasksInProgress int       `json:"tasks_in_progress"`
+	TasksCompleted  int       `json:"tasks_completed"`
+	TasksFailed     int       `json:"tasks_failed"`
+}
+
+type AgentList struct {
+	Agents []Agent `json:"agents"`
+}
+
+// AgentStatus - состояние агента на его /status
+type AgentStatus struct {
+	ID      string        `json:"id"`
+	Workers WorkersStatus `json:"workers"`
+	// QueueDepth - готовые задачи в очереди оркестратора по последнему ответу на запрос задачи
+	QueueDepth int `json:"queue_depth"`
+	// CPUUsage - доля процессорного времени всех ядер, занятая агентом за последний интервал
+	CPUUsage float64 `json:"cpu_usage"`
+	// Capabilities - операции, которые агент сообщает оркестратору
+	Capabilities []string `json:"capabilities"`
+}
+
+type WorkersStatus struct {
+	Autoscaling bool `json:"autoscaling"`
+	Target      int  `json:"target"`
+	Min         int  `json:"min"`
+	Max         int  `json:"max"`
+	Busy        int  `json:"busy"`
+}
+
+const (
+	PlanTaskNew      = "new"
+	PlanTaskInFlight = "in_flight"
+)
+
+// Plan - задачи, которые оркестратор создал бы для выражения, и оценка времени его вычисления
+type Plan struct {
+	Expression string     `json:"expression"`
+	Tasks      []PlanTask `json:"tasks"`
+	// Depth - число этапов: задачи одного этапа могут выполняться параллельно
+	Depth int `json:"depth"`
+	// CriticalPathMs - время вычисления при неограниченном числе агентов
+	CriticalPathMs int64 `json:"critical_path_ms"`
+	// ReusedOperations - операции, результат которых будет взят из кэша или других выражений
+	ReusedOperations int `json:"reused_operations"`
+	// Workers - суммарная ёмкость подключённых агентов
+	Workers int `json:"workers"`
+	// EstimatedMs - оценка времени вычисления на Workers агентах. Нет, если агентов нет
+	// или часть операций не может выполнить ни один из них
+	EstimatedMs *int64 `json:"estimated_ms,omitempty"`
+}
+
+// PlanTask - задача плана. Аргумент задан либо числом (Arg1), либо задачей, результат которой
+// в него подставится (Arg1Task)
+type PlanTask struct {
+	ID         string   `json:"id"`
+	Operation  string   `json:"operation"`
+	Arg1       *float64 `json:"arg1,omitempty"`
+	Arg2       *float64 `json:"arg2,omitempty"`
+	Arg1Task   string   `json:"arg1_task,omitempty"`
+	Arg2Task   string   `json:"arg2_task,omitempty"`
+	Stage      int      `json:"stage"`
+	Source     string   `json:"source"`
+	DurationMs int64    `json:"duration_ms"`
+	Critical   bool     `json:"critical"`
 }
 
 type CalculateRequest struct {
-	Expression string `json:"expression"`
+	Expression  string `json:"expression"`
+	CallbackURL string `json:"callback_url,omitempty"`
 }
 
 type ExpressionResponse struct {
 	Expressions []Expression `json:"expressions"`
+	NextCursor  string       `json:"next_cursor,omitempty"`
+}
+
+type BatchItemRequest struct {
+	Key        string `json:"key,omitempty"`
+	Expression string `json:"expression"`
+}
+
+type BatchRequest struct {
+	Expressions []BatchItemRequest `json:"expressions"`
+}
+
+// BatchItem - выражение пакета: ID при успешном приёме или ошибка валидации
+type BatchItem struct {
+	Key        string   `json:"key,omitempty"`
+	ID         string   `json:"id,omitempty"`
+	Expression string   `json:"expression"`
+	Status     string   `json:"status,omitempty"`
+	Result     *float64 `json:"result,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	ErrorCode  string   `json:"error_code,omitempty"`
+}
+
+type Batch struct {
+	ID         string      `json:"id"`
+	Status     string      `json:"status"`
+	CreatedAt  time.Time   `json:"created_at"`
+	Total      int         `json:"total"`
+	Rejected   int         `json:"rejected"`
+	Processing int         `json:"processing"`
+	Completed  int         `json:"completed"`
+	Failed     int         `json:"failed"`
+	Items      []BatchItem `json:"items"`
+}
+
+type AuthRequest struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+type LoginResponse struct {
+	APIKey string `json:"api_key"`
 }
@@ -3,23 +3,26 @@ package tests
 import (
 	"bytes"
 	"calculator-service/internal/api"
+	"calculator-service/internal/orchestrator"
+	"calculator-service/internal/types"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
-func TestCalculatorHandler_Calculate(t *testing.T) {
+func TestHandleEvaluate(t *testing.T) {
 	tests := []struct {
-		name           string
-		requestBody    interface{}
-		wantStatus     int
-		wantResult     *float64
-		wantErrMessage string
+		name        string
+		requestBody interface{}
+		wantStatus  int
+		wantResult  *float64
+		wantCode    string
 	}{
 		{
 			name: "корректное выражение",
-			requestBody: api.CalculateRequest{
+			requestBody: types.CalculateRequest{
 				Expression: "2+2*2",
 			},
 			wantStatus: http.StatusOK,
@@ -27,37 +30,46 @@ func TestCalculatorHandler_Calculate(t *testing.T) {
 		},
 		{
 			name: "некорректное выражение",
-			requestBody: api.CalculateRequest{
+			requestBody: types.CalculateRequest{
 				Expression: "2+a",
 			},
-			wantStatus:     http.StatusUnprocessableEntity,
-			wantErrMessage: "Expression is not valid",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   api.CodeInvalidExpression,
 		},
 		{
-			name:           "пустой запрос",
-			requestBody:    nil,
-			wantStatus:     http.StatusInternalServerError,
-			wantErrMessage: "Internal server error",
+			name:        "пустой запрос",
+			requestBody: nil,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    api.CodeInvalidBody,
 		},
 		{
 			name: "пустое выражение",
-			requestBody: api.CalculateRequest{
+			requestBody: types.CalculateRequest{
 				Expression: "",
 			},
-			wantStatus:     http.StatusUnprocessableEntity,
-			wantErrMessage: "Expression is not valid",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   api.CodeInvalidExpression,
 		},
 		{
 			name: "деление на ноль",
-			requestBody: api.CalculateRequest{
+			requestBody: types.CalculateRequest{
 				Expression: "1/0",
 			},
-			wantStatus:     http.StatusUnprocessableEntity,
-			wantErrMessage: "Expression is not valid",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   api.CodeInvalidExpression,
+		},
+		{
+			name: "callback_url не поддерживается",
+			requestBody: types.CalculateRequest{
+				Expression:  "1+1",
+				CallbackURL: "http://example.com/hook",
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   api.CodeInvalidCallbackURL,
 		},
 	}
 
-	handler := api.NewCalculatorHandler()
+	setupTest()
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -70,13 +82,13 @@ func TestCalculatorHandler_Calculate(t *testing.T) {
 				}
 			}
 
-			req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", bytes.NewReader(body))
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/evaluate", bytes.NewReader(body))
 			w := httptest.NewRecorder()
 
-			handler.Calculate(w, req)
+			orchestrator.HandleEvaluate(w, req)
 
 			if w.Code != tt.wantStatus {
-				t.Errorf("Calculate() status = %v, want %v", w.Code, tt.wantStatus)
+				t.Errorf("HandleEvaluate() status = %v, want %v", w.Code, tt.wantStatus)
 			}
 
 			if tt.wantResult != nil {
@@ -85,45 +97,84 @@ func TestCalculatorHandler_Calculate(t *testing.T) {
 					t.Fatal(err)
 				}
 				if response.Result != *tt.wantResult {
-					t.Errorf("Calculate() result = %v, want %v", response.Result, *tt.wantResult)
+					t.Errorf("HandleEvaluate() result = %v, want %v", response.Result, *tt.wantResult)
 				}
 			}
 
-			if tt.wantErrMessage != "" {
+			if tt.wantCode != "" {
 				var response api.ErrorResponse
 				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
 					t.Fatal(err)
 				}
-				if response.Error != tt.wantErrMessage {
-					t.Errorf("Calculate() error = %v, want %v", response.Error, tt.wantErrMessage)
+				if response.Code != tt.wantCode || response.Status != tt.wantStatus {
+					t.Errorf("HandleEvaluate() problem code = %q, status = %v, want %q", response.Code, response.Status, tt.wantCode)
 				}
 			}
 		})
 	}
 }
 
+// Локальное вычисление проверяет выражение так же, как распределённое
+func TestEvaluateSharesValidation(t *testing.T) {
+	setupTest()
+	defer orchestrator.SetLimits(orchestrator.DefaultLimits())
+
+	limits := orchestrator.DefaultLimits()
+	limits.MaxExpressionLength = 5
+	orchestrator.SetLimits(limits)
+
+	for _, handler := range []http.HandlerFunc{orchestrator.HandleCalculate, orchestrator.HandleEvaluate} {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodPost, "/api/v1/evaluate", strings.NewReader(`{"expression": "1+2+3+4"}`)))
+
+		var response api.ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		if w.Code != http.StatusUnprocessableEntity || response.Code != api.CodeExpressionTooLarge {
+			t.Errorf("код статуса = %v, код ошибки = %q, ожидается %v и %q", w.Code, response.Code, http.StatusUnprocessableEntity, api.CodeExpressionTooLarge)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleEvaluate(w, httptest.NewRequest(http.MethodPost, "/api/v1/evaluate", strings.NewReader(`{"expression": "1+2"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+	}
+
+	rec := httptest.NewRecorder()
+	orchestrator.HandleGetExpressions(rec, httptest.NewRequest(http.MethodGet, "/api/v1/expressions", nil))
+	var list types.ExpressionResponse
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Expressions) != 0 {
+		t.Errorf("вычисленное локально выражение попало в историю: %v", list.Expressions)
+	}
+}
+
 func TestSendErrorResponse(t *testing.T) {
 	tests := []struct {
 		name       string
 		status     int
+		code       string
 		message    string
 		wantStatus int
 	}{
 		{
 			name:       "Bad Request",
 			status:     http.StatusBadRequest,
+			code:       api.CodeInvalidBody,
 			message:    "Invalid input",
 			wantStatus: http.StatusBadRequest,
 		},
 		{
 			name:       "Not Found",
 			status:     http.StatusNotFound,
+			code:       api.CodeExpressionNotFound,
 			message:    "Resource not found",
 			wantStatus: http.StatusNotFound,
 		},
 		{
 			name:       "Internal Server Error",
 			status:     http.StatusInternalServerError,
+			code:       api.CodeInternal,
 			message:    "Server error",
 			wantStatus: http.StatusInternalServerError,
 		},
@@ -132,7 +183,8 @@ func TestSendErrorResponse(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			w := httptest.NewRecorder()
-			api.SendErrorResponse(w, tt.status, tt.message)
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/expressions/1", nil)
+			api.SendErrorResponse(w, req, tt.status, tt.code, tt.message)
 
 			if w.Code != tt.wantStatus {
 				t.Errorf("SendErrorResponse() status = %v, want %v", w.Code, tt.wantStatus)
@@ -144,13 +196,19 @@ func TestSendErrorResponse(t *testing.T) {
 				t.Errorf("Could not parse response: %v", err)
 			}
 
-			if response.Error != tt.message {
-				t.Errorf("SendErrorResponse() message = %v, want %v", response.Error, tt.message)
+			if response.Detail != tt.message {
+				t.Errorf("SendErrorResponse() message = %v, want %v", response.Detail, tt.message)
+			}
+			if response.Code != tt.code || response.Status != tt.status || response.Title != http.StatusText(tt.status) {
+				t.Errorf("SendErrorResponse() = %+v, want code %v and status %v", response, tt.code, tt.status)
+			}
+			if response.Type != "urn:calculator:error:"+tt.code || response.Instance != "/api/v1/expressions/1" {
+				t.Errorf("SendErrorResponse() type = %v, instance = %v", response.Type, response.Instance)
 			}
 
 			contentType := w.Header().Get("Content-Type")
-			if contentType != "application/json" {
-				t.Errorf("SendErrorResponse() Content-Type = %v, want application/json", contentType)
+			if contentType != api.ProblemContentType {
+				t.Errorf("SendErrorResponse() Content-Type = %v, want %v", contentType, api.ProblemContentType)
 			}
 		})
 	}
@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"calculator-service/internal/auth"
+	"calculator-service/internal/orchestrator"
+	"calculator-service/internal/types"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func loginUser(t *testing.T, login string) string {
+	t.Helper()
+
+	if _, err := auth.Register(login, "secret-password"); err != nil {
+		t.Fatalf("Register(%s) error = %v", login, err)
+	}
+	apiKey, err := auth.Login(login, "secret-password")
+	if err != nil {
+		t.Fatalf("Login(%s) error = %v", login, err)
+	}
+	return apiKey
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	auth.ResetState()
+
+	apiKey := loginUser(t, "alice")
+
+	if _, ok := auth.Authenticate(apiKey); !ok {
+		t.Errorf("Authenticate() не принял выданный ключ")
+	}
+	if _, ok := auth.Authenticate("ck_unknown"); ok {
+		t.Errorf("Authenticate() принял неизвестный ключ")
+	}
+
+	if _, err := auth.Register("alice", "another-password"); !errors.Is(err, auth.ErrUserExists) {
+		t.Errorf("Повторная регистрация: ошибка = %v, ожидается %v", err, auth.ErrUserExists)
+	}
+	if _, err := auth.Register("bob", "short"); !errors.Is(err, auth.ErrWeakPassword) {
+		t.Errorf("Короткий пароль: ошибка = %v, ожидается %v", err, auth.ErrWeakPassword)
+	}
+	if _, err := auth.Login("alice", "wrong-password"); !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Errorf("Неверный пароль: ошибка = %v, ожидается %v", err, auth.ErrInvalidCredentials)
+	}
+}
+
+func TestExpressionOwnership(t *testing.T) {
+	setupTest()
+	auth.ResetState()
+
+	aliceKey := loginUser(t, "alice")
+	bobKey := loginUser(t, "bob")
+
+	calculate := auth.RequireUser(http.HandlerFunc(orchestrator.HandleCalculate))
+	list := auth.RequireUser(http.HandlerFunc(orchestrator.HandleGetExpressions))
+	get := auth.RequireUser(http.HandlerFunc(orchestrator.HandleGetExpression))
+
+	unauthorized := httptest.NewRecorder()
+	calculate.ServeHTTP(unauthorized, httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(`{"expression": "2+2"}`)))
+	if unauthorized.Code != http.StatusUnauthorized {
+		t.Errorf("Запрос без ключа: код статуса = %v, ожидается %v", unauthorized.Code, http.StatusUnauthorized)
+	}
+
+	calcReq := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(`{"expression": "2+2"}`))
+	calcReq.Header.Set("Authorization", "Bearer "+aliceKey)
+	calcW := httptest.NewRecorder()
+	calculate.ServeHTTP(calcW, calcReq)
+
+	var calcResponse map[string]string
+	if err := json.Unmarshal(calcW.Body.Bytes(), &calcResponse); err != nil {
+		t.Fatalf("Невозможно распарсить ответ: %v", err)
+	}
+	exprID := calcResponse["id"]
+
+	for key, want := range map[string]int{aliceKey: 1, bobKey: 0} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/expressions", nil)
+		req.Header.Set(auth.APIKeyHeader, key)
+		w := httptest.NewRecorder()
+		list.ServeHTTP(w, req)
+
+		var response types.ExpressionResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Невозможно распарсить ответ: %v", err)
+		}
+		if len(response.Expressions) != want {
+			t.Errorf("Список выражений содержит %d элементов, ожидается %d", len(response.Expressions), want)
+		}
+	}
+
+	for key, want := range map[string]int{aliceKey: http.StatusOK, bobKey: http.StatusNotFound} {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/expressions/"+exprID, nil), map[string]string{"id": exprID})
+		req.Header.Set(auth.APIKeyHeader, key)
+		w := httptest.NewRecorder()
+		get.ServeHTTP(w, req)
+
+		if w.Code != want {
+			t.Errorf("Получение выражения: код статуса = %v, ожидается %v", w.Code, want)
+		}
+	}
+}
+
+func TestRequireAgentSecret(t *testing.T) {
+	setupTest()
+
+	handler := auth.RequireAgentSecret("agent-secret")(http.HandlerFunc(orchestrator.HandleGetTask))
+
+	for secret, want := range map[string]int{"": http.StatusUnauthorized, "wrong": http.StatusUnauthorized, "agent-secret": http.StatusNoContent} {
+		req := httptest.NewRequest(http.MethodGet, "/internal/task", nil)
+		if secret != "" {
+			req.Header.Set(auth.AgentSecretHeader, secret)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != want {
+			t.Errorf("Секрет %q: код статуса = %v, ожидается %v", secret, w.Code, want)
+		}
+	}
+}
+
+func TestAuthLimits(t *testing.T) {
+	auth.ResetState()
+	defer orchestrator.SetLimits(orchestrator.DefaultLimits())
+
+	limits := orchestrator.DefaultLimits()
+	limits.AuthRateLimitRPS = 0.01
+	limits.AuthRateLimitBurst = 2
+	limits.MaxUsers = 1
+	orchestrator.SetLimits(limits)
+
+	register := orchestrator.AuthRateLimit(http.HandlerFunc(auth.HandleRegister))
+	login := orchestrator.AuthRateLimit(http.HandlerFunc(auth.HandleLogin))
+	post := func(handler http.Handler, remoteAddr, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/register", strings.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post(register, "192.0.2.1:1000", `{"login": "alice", "password": "secret-password"}`); w.Code != http.StatusCreated {
+		t.Fatalf("регистрация: код статуса = %v, ожидается %v", w.Code, http.StatusCreated)
+	}
+	if w := post(register, "192.0.2.2:1000", `{"login": "bob", "password": "secret-password"}`); w.Code != http.StatusForbidden {
+		t.Errorf("регистрация сверх MAX_USERS: код статуса = %v, ожидается %v", w.Code, http.StatusForbidden)
+	}
+
+	// Лимит общий для регистрации и входа, но у каждого IP свой
+	if w := post(login, "192.0.2.1:1000", `{"login": "alice", "password": "wrong-password"}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("вход в пределах burst: код статуса = %v, ожидается %v", w.Code, http.StatusUnauthorized)
+	}
+	if w := post(login, "192.0.2.1:2000", `{"login": "alice", "password": "secret-password"}`); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("вход сверх лимита: код статуса = %v, ожидается %v с Retry-After", w.Code, http.StatusTooManyRequests)
+	}
+	if w := post(login, "192.0.2.3:1000", `{"login": "alice", "password": "secret-password"}`); w.Code != http.StatusOK {
+		t.Errorf("вход с другого IP: код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+	}
+
+	big := `{"login": "carol", "password": "` + strings.Repeat("x", 8<<10) + `"}`
+	if w := post(http.HandlerFunc(auth.HandleRegister), "192.0.2.4:1000", big); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("большое тело: код статуса = %v, ожидается %v", w.Code, http.StatusRequestEntityTooLarge)
+	}
+}
@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"bytes"
+	"calculator-service/internal/orchestrator"
+	"calculator-service/internal/types"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func getBatch(t *testing.T, id string) types.Batch {
+	t.Helper()
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/batches/"+id, nil), map[string]string{"id": id})
+	w := httptest.NewRecorder()
+	orchestrator.HandleGetBatch(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("HandleGetBatch() код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+	}
+
+	var b types.Batch
+	if err := json.Unmarshal(w.Body.Bytes(), &b); err != nil {
+		t.Fatalf("Невозможно распарсить ответ: %v", err)
+	}
+	return b
+}
+
+func TestCalculateBatch(t *testing.T) {
+	setupTest()
+
+	body := `{"expressions": [
+		{"key": "a", "expression": "2*3"},
+		{"key": "b", "expression": "7"},
+		{"key": "c", "expression": "2+a"},
+		{"key": "a", "expression": "1+1"}
+	]}`
+	w := httptest.NewRecorder()
+	orchestrator.HandleCalculateBatch(w, httptest.NewRequest(http.MethodPost, "/api/v1/calculate/batch", strings.NewReader(body)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("HandleCalculateBatch() код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+	}
+
+	var created types.Batch
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Невозможно распарсить ответ: %v", err)
+	}
+
+	if created.Total != 4 || created.Rejected != 2 {
+		t.Fatalf("total = %d, rejected = %d, ожидается 4 и 2", created.Total, created.Rejected)
+	}
+	if created.Items[0].ID == "" || created.Items[1].ID == "" {
+		t.Errorf("Корректные выражения должны получить ID: %+v", created.Items)
+	}
+	if created.Items[2].Error == "" || created.Items[3].Error == "" {
+		t.Errorf("Некорректное выражение и повторный ключ должны вернуть ошибку: %+v", created.Items)
+	}
+	if created.Status != types.StatusProcessing {
+		t.Errorf("status = %s, ожидается %s", created.Status, types.StatusProcessing)
+	}
+
+	taskW := httptest.NewRecorder()
+	orchestrator.HandleGetTask(taskW, httptest.NewRequest(http.MethodGet, "/internal/task", nil))
+	var task types.Task
+	if err := json.Unmarshal(taskW.Body.Bytes(), &task); err != nil {
+		t.Fatalf("Невозможно распарсить ответ: %v", err)
+	}
+	resultBody, _ := json.Marshal(types.TaskResult{ID: task.ID, Result: 6})
+	orchestrator.HandleSubmitTaskResult(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/internal/task", bytes.NewReader(resultBody)))
+
+	finished := getBatch(t, created.ID)
+	if finished.Status != types.StatusCompleted || finished.Completed != 2 {
+		t.Fatalf("После выполнения задач: status = %s, completed = %d", finished.Status, finished.Completed)
+	}
+	if finished.Items[0].Result == nil || *finished.Items[0].Result != 6 {
+		t.Errorf("Результат элемента a = %v, ожидается 6", finished.Items[0].Result)
+	}
+	if finished.Items[1].Result == nil || *finished.Items[1].Result != 7 {
+		t.Errorf("Результат элемента b = %v, ожидается 7", finished.Items[1].Result)
+	}
+}
+
+func TestCalculateBatchValidation(t *testing.T) {
+	setupTest()
+
+	limits := orchestrator.DefaultLimits()
+	limits.MaxBatchSize = 2
+	orchestrator.SetLimits(limits)
+	defer orchestrator.SetLimits(orchestrator.DefaultLimits())
+
+	for _, body := range []string{
+		`{"expressions": []}`,
+		`{"expressions": [{"expression": "1"}, {"expression": "2"}, {"expression": "3"}]}`,
+	} {
+		w := httptest.NewRecorder()
+		orchestrator.HandleCalculateBatch(w, httptest.NewRequest(http.MethodPost, "/api/v1/calculate/batch", strings.NewReader(body)))
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Тело %s: код статуса = %v, ожидается %v", body, w.Code, http.StatusUnprocessableEntity)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/batches/unknown", nil), map[string]string{"id": "unknown"})
+	orchestrator.HandleGetBatch(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Неизвестный пакет: код статуса = %v, ожидается %v", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestBatchTTL(t *testing.T) {
+	setupTest()
+	limits := orchestrator.DefaultLimits()
+	limits.BatchTTL = 20 * time.Millisecond
+	orchestrator.SetLimits(limits)
+	defer orchestrator.SetLimits(orchestrator.DefaultLimits())
+
+	create := func(body string) string {
+		w := httptest.NewRecorder()
+		orchestrator.HandleCalculateBatch(w, httptest.NewRequest(http.MethodPost, "/api/v1/calculate/batch", strings.NewReader(body)))
+		var created types.Batch
+		if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+			t.Fatalf("Невозможно распарсить ответ: %v", err)
+		}
+		return created.ID
+	}
+	lookup := func(id string) int {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/batches/"+id, nil), map[string]string{"id": id})
+		w := httptest.NewRecorder()
+		orchestrator.HandleGetBatch(w, req)
+		return w.Code
+	}
+
+	finished := create(`{"expressions": [{"expression": "7"}]}`)
+	running := create(`{"expressions": [{"expression": "2*3"}]}`)
+	time.Sleep(30 * time.Millisecond)
+
+	if code := lookup(finished); code != http.StatusNotFound {
+		t.Errorf("завершённый пакет после BATCH_TTL: код статуса = %v, ожидается %v", code, http.StatusNotFound)
+	}
+	// Пакет с выражениями в обработке хранится, пока они не завершатся
+	if code := lookup(running); code != http.StatusOK {
+		t.Errorf("пакет в обработке после BATCH_TTL: код статуса = %v, ожидается %v", code, http.StatusOK)
+	}
+	completeAllTasks()
+	if code := lookup(running); code != http.StatusNotFound {
+		t.Errorf("пакет после завершения: код статуса = %v, ожидается %v", code, http.StatusNotFound)
+	}
+}
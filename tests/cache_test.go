@@ -0,0 +1,210 @@
+package tests
+
+import (
+	"bytes"
+	"calculator-service/internal/orchestrator"
+	"calculator-service/internal/types"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func submitExpression(t *testing.T, expression string) string {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleCalculate(w, httptest.NewRequest(http.MethodPost, "/api/v1/calculate",
+		strings.NewReader(`{"expression": "`+expression+`"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("HandleCalculate() код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+	}
+
+	var response map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Невозможно распарсить ответ: %v", err)
+	}
+	return response["id"]
+}
+
+func getExpression(t *testing.T, id string) types.Expression {
+	t.Helper()
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/expressions/"+id, nil), map[string]string{"id": id})
+	w := httptest.NewRecorder()
+	orchestrator.HandleGetExpression(w, req)
+
+	var expr types.Expression
+	if err := json.Unmarshal(w.Body.Bytes(), &expr); err != nil {
+		t.Fatalf("Невозможно распарсить ответ: %v", err)
+	}
+	return expr
+}
+
+// completeAllTasks выполняет все задачи очереди и возвращает их количество
+func completeAllTasks() int {
+	count := 0
+	for {
+		w := httptest.NewRecorder()
+		orchestrator.HandleGetTask(w, httptest.NewRequest(http.MethodGet, "/internal/task", nil))
+		if w.Code != http.StatusOK {
+			return count
+		}
+		count++
+
+		var task types.Task
+		json.Unmarshal(w.Body.Bytes(), &task)
+		body, _ := json.Marshal(types.TaskResult{ID: task.ID, Result: calculateResultTest(task)})
+		orchestrator.HandleSubmitTaskResult(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/internal/task", bytes.NewReader(body)))
+	}
+}
+
+func TestResultCache(t *testing.T) {
+	setupTest()
+
+	leaderID := submitExpression(t, "2 + 3*4")
+	followerID := submitExpression(t, "2+(3*4)")
+
+	if follower := getExpression(t, followerID); follower.Status != types.StatusProcessing || !follower.Cached {
+		t.Fatalf("Такое же выражение должно ждать выполняющееся, получено %+v", follower)
+	}
+
+	if tasks := completeAllTasks(); tasks != 2 {
+		t.Errorf("Выполнено задач = %v, ожидается 2: одинаковые выражения не должны планироваться дважды", tasks)
+	}
+
+	for _, id := range []string{leaderID, followerID} {
+		expr := getExpression(t, id)
+		if expr.Status != types.StatusCompleted || expr.Result != 14 {
+			t.Errorf("Выражение %s: статус = %v, результат = %v, ожидается COMPLETED и 14", id, expr.Status, expr.Result)
+		}
+	}
+
+	cachedID := submitExpression(t, "2.0+3*4")
+	cached := getExpression(t, cachedID)
+	if cached.Status != types.StatusCompleted || cached.Result != 14 || !cached.Cached {
+		t.Errorf("Ожидается результат из кэша, получено %+v", cached)
+	}
+	if tasks := completeAllTasks(); tasks != 0 {
+		t.Errorf("Для результата из кэша создано задач = %v", tasks)
+	}
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{
+		`calc_expression_cache_total{outcome="hit"}`,
+		`calc_expression_cache_total{outcome="shared"}`,
+		`calc_expression_cache_entries 2`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("Метрики не содержат %q", want)
+		}
+	}
+}
+
+func TestResultCacheLimits(t *testing.T) {
+	setupTest()
+
+	limits := orchestrator.DefaultLimits()
+	limits.CacheSize = 1
+	orchestrator.SetLimits(limits)
+	defer orchestrator.SetLimits(orchestrator.DefaultLimits())
+
+	submitExpression(t, "1+1")
+	completeAllTasks()
+	submitExpression(t, "2+2")
+	completeAllTasks()
+
+	if expr := getExpression(t, submitExpression(t, "1+1")); expr.Cached {
+		t.Errorf("Вытесненный результат не должен браться из кэша")
+	}
+	completeAllTasks()
+
+	limits.CacheSize = 0
+	orchestrator.SetLimits(limits)
+
+	if expr := getExpression(t, submitExpression(t, "1+1")); expr.Cached || expr.Status != types.StatusProcessing {
+		t.Errorf("С выключенным кэшем выражение должно вычисляться заново, получено %+v", expr)
+	}
+}
+
+func TestSubexpressionReuse(t *testing.T) {
+	setupTest()
+
+	firstID := submitExpression(t, "(2+3)*4")
+	secondID := submitExpression(t, "(3 + 2)*5")
+
+	second := getExpression(t, secondID)
+	if second.Metrics == nil || second.Metrics.ReusedOperations != 1 {
+		t.Fatalf("Поддерево 3+2 должно переиспользовать задачу первого выражения, метрики = %+v", second.Metrics)
+	}
+
+	if tasks := completeAllTasks(); tasks != 3 {
+		t.Errorf("Выполнено задач = %v, ожидается 3", tasks)
+	}
+	for id, want := range map[string]float64{firstID: 20, secondID: 25} {
+		if expr := getExpression(t, id); expr.Status != types.StatusCompleted || expr.Result != want {
+			t.Errorf("Выражение %s: статус = %v, результат = %v, ожидается %v", id, expr.Status, expr.Result, want)
+		}
+	}
+
+	thirdID := submitExpression(t, "(2+3)*6")
+	if tasks := completeAllTasks(); tasks != 1 {
+		t.Errorf("Для выражения с вычисленным поддеревом выполнено задач = %v, ожидается 1", tasks)
+	}
+	if third := getExpression(t, thirdID); third.Result != 30 || third.Metrics.ReusedOperations != 1 {
+		t.Errorf("Ожидается результат 30 и одна переиспользованная операция, получено %+v", third)
+	}
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{
+		`calc_planned_operations_total{source="in_flight"}`,
+		`calc_planned_operations_total{source="cached"}`,
+		`calc_operation_reuse_ratio `,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("Метрики не содержат %q", want)
+		}
+	}
+}
+
+func TestTaskWithTwoDependencies(t *testing.T) {
+	setupTest()
+
+	id := submitExpression(t, "(1+2)*(3+4)+(1+2)")
+
+	var dispatched []types.Task
+	for {
+		w := httptest.NewRecorder()
+		orchestrator.HandleGetTask(w, httptest.NewRequest(http.MethodGet, "/internal/task", nil))
+		if w.Code != http.StatusOK {
+			break
+		}
+
+		var task types.Task
+		json.Unmarshal(w.Body.Bytes(), &task)
+		dispatched = append(dispatched, task)
+		body, _ := json.Marshal(types.TaskResult{ID: task.ID, Result: calculateResultTest(task)})
+		orchestrator.HandleSubmitTaskResult(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/internal/task", bytes.NewReader(body)))
+	}
+
+	if len(dispatched) != 4 {
+		t.Fatalf("Выдано задач = %v, ожидается 4: повторное поддерево 1+2 вычисляется один раз", len(dispatched))
+	}
+	for _, task := range dispatched {
+		if task.Operation == "*" && (task.Arg1 != 3 || task.Arg2 != 7) {
+			t.Errorf("Задача умножения получила аргументы %v и %v, ожидается 3 и 7", task.Arg1, task.Arg2)
+		}
+		if task.Operation == "+" && task.Arg1 == 21 && task.Arg2 != 3 {
+			t.Errorf("Итоговая задача сложения получила аргументы %v и %v, ожидается 21 и 3", task.Arg1, task.Arg2)
+		}
+	}
+
+	if expr := getExpression(t, id); expr.Status != types.StatusCompleted || expr.Result != 24 {
+		t.Errorf("Статус = %v, результат = %v, ожидается COMPLETED и 24", expr.Status, expr.Result)
+	}
+}
@@ -196,3 +196,25 @@ func TestRPNPriority(t *testing.T) {
 		})
 	}
 }
+
+func TestCalcVariables(t *testing.T) {
+	calc := calculator.NewCalculator()
+	calc.Variables = map[string]float64{"x": 12, "neg_1": -3}
+
+	got, err := calc.Calculate("x/4 + neg_1*2")
+	if err != nil {
+		t.Fatalf("Calculate() error = %v", err)
+	}
+	if got != -3 {
+		t.Errorf("Calculate() = %v, ожидается -3", got)
+	}
+
+	if _, err := calc.Calculate("y+1"); err == nil || !strings.Contains(err.Error(), "unknown variable: y") {
+		t.Errorf("Calculate() с неизвестной переменной error = %v", err)
+	}
+
+	// Без переменных имена по-прежнему недопустимы, как в API оркестратора
+	if _, err := calculator.Calc("x+1"); err == nil || !strings.Contains(err.Error(), "invalid character") {
+		t.Errorf("Calc() error = %v, ожидается invalid character", err)
+	}
+}
@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"calculator-service/internal/api"
+	"calculator-service/internal/auth"
+	"calculator-service/internal/orchestrator"
+	"calculator-service/internal/types"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func cancelExpression(t *testing.T, id string) (types.Expression, *httptest.ResponseRecorder) {
+	t.Helper()
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/api/v1/expressions/"+id+"/cancel", nil), map[string]string{"id": id})
+	w := httptest.NewRecorder()
+	orchestrator.HandleCancelExpression(w, req)
+
+	var expr types.Expression
+	if w.Code == http.StatusOK {
+		json.Unmarshal(w.Body.Bytes(), &expr)
+	}
+	return expr, w
+}
+
+func TestCancelExpression(t *testing.T) {
+	setupTest()
+
+	id := submitExpression(t, "2+3*4")
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/api/v1/expressions/"+id+"/cancel", nil), map[string]string{"id": id})
+	w := httptest.NewRecorder()
+	orchestrator.HandleCancelExpression(w, req.WithContext(auth.WithUserID(req.Context(), "other-user")))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Отмена чужого выражения: код статуса = %v, ожидается %v", w.Code, http.StatusNotFound)
+	}
+
+	expr, w := cancelExpression(t, id)
+	if w.Code != http.StatusOK {
+		t.Fatalf("код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+	}
+	if expr.Status != types.StatusError || expr.Error != "cancelled" || expr.CompletedAt == nil {
+		t.Errorf("Отменённое выражение = %+v, ожидается ERROR с ошибкой cancelled", expr)
+	}
+	if n := completeAllTasks(); n != 0 {
+		t.Errorf("После отмены в очереди осталось %d задач, ожидается 0", n)
+	}
+
+	_, w = cancelExpression(t, id)
+	var problem api.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &problem)
+	if w.Code != http.StatusConflict || problem.Code != api.CodeExpressionFinished {
+		t.Errorf("Повторная отмена: код статуса = %v, код ошибки = %q, ожидается %v и %q", w.Code, problem.Code, http.StatusConflict, api.CodeExpressionFinished)
+	}
+}
+
+// Отмена выражения не должна мешать выражениям, которые используют его задачи
+func TestCancelSharedExpression(t *testing.T) {
+	setupTest()
+
+	leaderID := submitExpression(t, "2+3*4")
+	followerID := submitExpression(t, "2+(3*4)")
+	borrowerID := submitExpression(t, "3*4+1")
+
+	if _, w := cancelExpression(t, leaderID); w.Code != http.StatusOK {
+		t.Fatalf("код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+	}
+	if follower := getExpression(t, followerID); follower.Status != types.StatusProcessing {
+		t.Fatalf("Присоединённое выражение должно продолжить выполнение, статус = %v", follower.Status)
+	}
+
+	completeAllTasks()
+
+	if leader := getExpression(t, leaderID); leader.Status != types.StatusError || leader.Error != "cancelled" {
+		t.Errorf("Отменённое выражение: статус = %v, ошибка = %q", leader.Status, leader.Error)
+	}
+	if follower := getExpression(t, followerID); follower.Status != types.StatusCompleted || follower.Result != 14 {
+		t.Errorf("Присоединённое выражение: статус = %v, результат = %v, ожидается COMPLETED и 14", follower.Status, follower.Result)
+	}
+	if borrower := getExpression(t, borrowerID); borrower.Status != types.StatusCompleted || borrower.Result != 13 {
+		t.Errorf("Выражение с общей задачей: статус = %v, результат = %v, ожидается COMPLETED и 13", borrower.Status, borrower.Result)
+	}
+}
+
+// Общее поддерево из нескольких задач: задачи под его корнем тоже нужны второму выражению
+func TestCancelSharedSubtree(t *testing.T) {
+	setupTest()
+
+	ownerID := submitExpression(t, "(1+2)*(3+4)")
+	borrowerID := submitExpression(t, "(1+2)*(3+4)-5")
+
+	if _, w := cancelExpression(t, ownerID); w.Code != http.StatusOK {
+		t.Fatalf("код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+	}
+	if tasks := completeAllTasks(); tasks != 4 {
+		t.Errorf("Выполнено задач = %v, ожидается 4", tasks)
+	}
+
+	if borrower := getExpression(t, borrowerID); borrower.Status != types.StatusCompleted || borrower.Result != 16 {
+		t.Errorf("Выражение с общим поддеревом: статус = %v, результат = %v, ожидается COMPLETED и 16", borrower.Status, borrower.Result)
+	}
+}
+
+func TestCancelFollower(t *testing.T) {
+	setupTest()
+
+	leaderID := submitExpression(t, "5*6")
+	followerID := submitExpression(t, "5 * 6")
+
+	if _, w := cancelExpression(t, followerID); w.Code != http.StatusOK {
+		t.Fatalf("код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+	}
+	completeAllTasks()
+
+	if leader := getExpression(t, leaderID); leader.Status != types.StatusCompleted || leader.Result != 30 {
+		t.Errorf("Исходное выражение: статус = %v, результат = %v, ожидается COMPLETED и 30", leader.Status, leader.Result)
+	}
+	if follower := getExpression(t, followerID); follower.Status != types.StatusError {
+		t.Errorf("Отменённое присоединённое выражение получило статус %v", follower.Status)
+	}
+}
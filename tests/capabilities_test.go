@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"bytes"
+	"calculator-service/internal/orchestrator"
+	"calculator-service/internal/types"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func requestTaskAs(t *testing.T, id, capabilities string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/internal/task", nil)
+	req.Header.Set(types.AgentIDHeader, id)
+	req.Header.Set(types.ProtocolVersionHeader, "3")
+	if capabilities != "" {
+		req.Header.Set(types.AgentCapabilitiesHeader, capabilities)
+	}
+	w := httptest.NewRecorder()
+	orchestrator.HandleGetTask(w, req)
+	return w
+}
+
+func TestParseCapabilities(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"/", []string{"/"}},
+		{"+, -,*,/", []string{"+", "-", "*", "/"}},
+		{" / ,,/, high-precision ", []string{"/", "high-precision"}},
+	}
+
+	for _, tt := range tests {
+		if got := types.ParseCapabilities(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseCapabilities(%q) = %q, ожидается %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCapabilityRouting(t *testing.T) {
+	setupTest()
+	submitExpression(t, "1+2")
+	submitExpression(t, "6/3")
+
+	// Агент только для деления не получает сложение, даже когда деление уже выдано
+	w := requestTaskAs(t, "divider", "/")
+	if w.Code != http.StatusOK {
+		t.Fatalf("код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+	}
+	var task types.Task
+	if err := json.Unmarshal(w.Body.Bytes(), &task); err != nil {
+		t.Fatalf("Невозможно распарсить задачу: %v", err)
+	}
+	if task.Operation != "/" {
+		t.Fatalf("операция = %q, ожидается /", task.Operation)
+	}
+	if depth := w.Header().Get(types.QueueDepthHeader); depth != "0" {
+		t.Errorf("%s = %q, ожидается 0: сложение агенту недоступно", types.QueueDepthHeader, depth)
+	}
+
+	w = requestTaskAs(t, "divider", "/")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("код статуса = %v, ожидается %v", w.Code, http.StatusNoContent)
+	}
+
+	// Агент без заголовка выполняет все базовые операции
+	w = requestTaskAs(t, "legacy", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &task); err != nil {
+		t.Fatalf("Невозможно распарсить задачу: %v", err)
+	}
+	if task.Operation != "+" {
+		t.Fatalf("операция = %q, ожидается +", task.Operation)
+	}
+
+	w = httptest.NewRecorder()
+	orchestrator.HandleGetAgents(w, httptest.NewRequest(http.MethodGet, "/api/v1/agents", nil))
+	var list types.AgentList
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Невозможно распарсить ответ: %v", err)
+	}
+	want := map[string][]string{
+		"divider": {"/"},
+		"legacy":  types.BasicOperations,
+	}
+	for _, agent := range list.Agents {
+		if !reflect.DeepEqual(agent.Capabilities, want[agent.ID]) {
+			t.Errorf("возможности агента %s = %q, ожидается %q", agent.ID, agent.Capabilities, want[agent.ID])
+		}
+	}
+}
+
+// Агент сообщает возможности только при запросе задач: отправка результата их не сбрасывает
+func TestCapabilitiesKeptOnResult(t *testing.T) {
+	setupTest()
+	id := submitExpression(t, "6/3")
+	submitExpression(t, "1+2")
+
+	w := requestTaskAs(t, "divider", "/")
+	var task types.Task
+	if err := json.Unmarshal(w.Body.Bytes(), &task); err != nil {
+		t.Fatalf("Невозможно распарсить задачу: %v", err)
+	}
+
+	body, _ := json.Marshal(types.TaskResult{ID: task.ID, Result: 2})
+	req := httptest.NewRequest(http.MethodPost, "/internal/task", bytes.NewReader(body))
+	req.Header.Set(types.AgentIDHeader, "divider")
+	req.Header.Set(types.ProtocolVersionHeader, "3")
+	w = httptest.NewRecorder()
+	orchestrator.HandleSubmitTaskResult(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+	}
+	if expr := getExpression(t, id); expr.Status != types.StatusCompleted {
+		t.Fatalf("статус = %v, ожидается %v", expr.Status, types.StatusCompleted)
+	}
+
+	w = httptest.NewRecorder()
+	orchestrator.HandleGetAgents(w, httptest.NewRequest(http.MethodGet, "/api/v1/agents", nil))
+	var list types.AgentList
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Невозможно распарсить ответ: %v", err)
+	}
+	if len(list.Agents) != 1 || !reflect.DeepEqual(list.Agents[0].Capabilities, []string{"/"}) {
+		t.Errorf("агенты = %+v, ожидается divider с возможностями [/]", list.Agents)
+	}
+
+	// Сложение по-прежнему невыполнимо: агент так и умеет только делить
+	if expr := getExpression(t, submitExpression(t, "3+4")); !reflect.DeepEqual(expr.Unschedulable, []string{"+"}) {
+		t.Errorf("unschedulable_operations = %q, ожидается [+]", expr.Unschedulable)
+	}
+}
+
+func TestUnschedulableTasks(t *testing.T) {
+	setupTest()
+	id := submitExpression(t, "1+2")
+
+	// Пока агентов нет, задача просто ждёт
+	if expr := getExpression(t, id); len(expr.Unschedulable) != 0 {
+		t.Fatalf("unschedulable_operations = %q без агентов, ожидается пусто", expr.Unschedulable)
+	}
+
+	requestTaskAs(t, "divider", "/,high-precision")
+	if expr := getExpression(t, id); !reflect.DeepEqual(expr.Unschedulable, []string{"+"}) {
+		t.Fatalf("unschedulable_operations = %q, ожидается [+]", expr.Unschedulable)
+	}
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if line := `calc_tasks_unschedulable{operation="+"} 1`; !strings.Contains(w.Body.String(), line) {
+		t.Errorf("метрики не содержат %q", line)
+	}
+
+	// Агент со сложением выполняет задачу, и она перестаёт быть невыполнимой
+	requestTaskAs(t, "adder", "+,-")
+	if expr := getExpression(t, id); len(expr.Unschedulable) != 0 {
+		t.Errorf("unschedulable_operations = %q, ожидается пусто", expr.Unschedulable)
+	}
+}
@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"bytes"
+	"calculator-service/internal/api"
+	"calculator-service/internal/auth"
+	"calculator-service/internal/client"
+	"calculator-service/internal/orchestrator"
+	"calculator-service/internal/types"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// newTestServer поднимает публичный API оркестратора с теми же маршрутами, что cmd/orchestrator
+func newTestServer() *httptest.Server {
+	r := mux.NewRouter()
+	v1 := r.PathPrefix("/api/v1").Subrouter()
+	v1.Use(auth.RequireUser)
+	v1.HandleFunc("/calculate", orchestrator.HandleCalculate).Methods("POST")
+	v1.HandleFunc("/calculate/batch", orchestrator.HandleCalculateBatch).Methods("POST")
+	v1.HandleFunc("/batches/{id}", orchestrator.HandleGetBatch).Methods("GET")
+	v1.HandleFunc("/expressions", orchestrator.HandleGetExpressions).Methods("GET")
+	v1.HandleFunc("/expressions/{id}", orchestrator.HandleGetExpression).Methods("GET")
+	v1.HandleFunc("/expressions/{id}/cancel", orchestrator.HandleCancelExpression).Methods("POST")
+	v1.HandleFunc("/agents", orchestrator.HandleGetAgents).Methods("GET")
+	return httptest.NewServer(r)
+}
+
+func TestClient(t *testing.T) {
+	setupTest()
+	auth.ResetState()
+
+	server := newTestServer()
+	defer server.Close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go runTestAgent(stop)
+
+	c := client.New(server.URL, loginUser(t, "cli-user"))
+	ctx := context.Background()
+
+	expr, err := c.Submit(ctx, "2+2*2", 5*time.Second)
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if expr.Status != types.StatusCompleted || expr.Result != 6 {
+		t.Errorf("Submit() = %+v, ожидается COMPLETED и 6", expr)
+	}
+
+	batch, err := c.SubmitBatch(ctx, []string{"1+1", "2+a"})
+	if err != nil {
+		t.Fatalf("SubmitBatch() error = %v", err)
+	}
+	if batch.Total != 2 || batch.Rejected != 1 {
+		t.Errorf("SubmitBatch() = %+v, ожидается 2 выражения и 1 отклонённое", batch)
+	}
+
+	var progress []types.Expression
+	watched, err := c.Watch(ctx, batch.Items[0].ID, 10*time.Millisecond, func(e types.Expression) {
+		progress = append(progress, e)
+	})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	if watched.Status != types.StatusCompleted || watched.Result != 2 || len(progress) == 0 {
+		t.Errorf("Watch() = %+v после %d обновлений", watched, len(progress))
+	}
+
+	list, err := c.List(ctx, client.ListOptions{Status: types.StatusCompleted, Limit: 1})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(list.Expressions) != 1 || list.NextCursor == "" {
+		t.Errorf("List() вернул %d выражений, курсор %q, ожидается 1 и курсор", len(list.Expressions), list.NextCursor)
+	}
+
+	_, err = c.Cancel(ctx, expr.ID)
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusConflict || apiErr.Code != api.CodeExpressionFinished {
+		t.Errorf("Cancel() завершённого выражения error = %v, ожидается %q", err, api.CodeExpressionFinished)
+	}
+
+	agents, err := c.Agents(ctx)
+	if err != nil {
+		t.Fatalf("Agents() error = %v", err)
+	}
+	if len(agents) != 1 || agents[0].Status != types.AgentOnline || agents[0].TasksCompleted == 0 {
+		t.Errorf("Agents() = %+v, ожидается один активный агент", agents)
+	}
+
+	_, err = client.New(server.URL, "wrong-key").Get(ctx, expr.ID)
+	if !errors.As(err, &apiErr) || apiErr.Code != api.CodeUnauthorized {
+		t.Errorf("Get() с неверным ключом error = %v, ожидается %q", err, api.CodeUnauthorized)
+	}
+}
+
+// Ответ об ошибке не в формате problem+json, например от прокси, не теряет подробностей
+func TestClientPlainError(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantDetail string
+		wantError  string
+	}{
+		{"html прокси", "<html>upstream connect error</html>\n", "<html>upstream connect error</html>", "502 Bad Gateway: <html>upstream connect error</html>"},
+		{"json без code", `{"error": "backend down"}`, `{"error": "backend down"}`, `502 Bad Gateway: {"error": "backend down"}`},
+		{"пустое тело", "", "", "502 Bad Gateway"},
+		{"длинное тело", strings.Repeat("x", 2000), strings.Repeat("x", 512) + "...", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				w.WriteHeader(http.StatusBadGateway)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := client.New(server.URL, "").Get(context.Background(), "some-id")
+			var apiErr *client.Error
+			if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadGateway || apiErr.Code != "" {
+				t.Fatalf("Get() error = %#v, ожидается client.Error со статусом 502 без кода", err)
+			}
+			if apiErr.Detail != tt.wantDetail {
+				t.Errorf("Detail = %q, ожидается %q", apiErr.Detail, tt.wantDetail)
+			}
+			if tt.wantError != "" && err.Error() != tt.wantError {
+				t.Errorf("Error() = %q, ожидается %q", err.Error(), tt.wantError)
+			}
+		})
+	}
+}
+
+func TestAgentTracking(t *testing.T) {
+	setupTest()
+
+	submitExpression(t, "1+2")
+	submitExpression(t, "3/4")
+
+	take := func(agentID string) types.Task {
+		req := httptest.NewRequest(http.MethodGet, "/internal/task", nil)
+		req.Header.Set(types.AgentIDHeader, agentID)
+		req.Header.Set(types.ProtocolVersionHeader, "2")
+		w := httptest.NewRecorder()
+		orchestrator.HandleGetTask(w, req)
+		var task types.Task
+		json.Unmarshal(w.Body.Bytes(), &task)
+		return task
+	}
+	first := take("agent-a")
+	take("agent-b")
+
+	body, _ := json.Marshal(types.TaskResult{ID: first.ID, Result: calculateResultTest(first)})
+	req := httptest.NewRequest(http.MethodPost, "/internal/task", bytes.NewReader(body))
+	req.Header.Set(types.AgentIDHeader, "agent-a")
+	orchestrator.HandleSubmitTaskResult(httptest.NewRecorder(), req)
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleGetAgents(w, httptest.NewRequest(http.MethodGet, "/api/v1/agents", nil))
+	var list types.AgentList
+	json.Unmarshal(w.Body.Bytes(), &list)
+
+	if len(list.Agents) != 2 {
+		t.Fatalf("Агентов = %d, ожидается 2", len(list.Agents))
+	}
+	a, b := list.Agents[0], list.Agents[1]
+	if a.ID != "agent-a" || a.TasksCompleted != 1 || a.TasksInProgress != 0 || a.ProtocolVersion != 1 {
+		t.Errorf("agent-a = %+v, ожидается 1 выполненная задача и версия 1 последнего запроса", a)
+	}
+	if b.ID != "agent-b" || b.TasksInProgress != 1 || b.ProtocolVersion != 2 {
+		t.Errorf("agent-b = %+v, ожидается 1 задача в работе", b)
+	}
+}
@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"calculator-service/internal/config"
+	"calculator-service/internal/orchestrator"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Невозможно записать файл конфигурации: %v", err)
+	}
+}
+
+func TestConfigLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, `{"MAX_OPERATORS": 50, "RESULT_CACHE_TTL": "5m", "TRACING": false}`)
+	t.Setenv("MAX_OPERATORS", "10")
+	t.Setenv("MAX_BATCH_SIZE", "7")
+
+	source, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"MAX_OPERATORS", "50"}, // файл перекрывает окружение
+		{"MAX_BATCH_SIZE", "7"},
+		{"RESULT_CACHE_TTL", "5m"},
+		{"TRACING", "false"},
+		{"MAX_WAIT", ""},
+	}
+	for _, tt := range tests {
+		if got := source.Get(tt.key); got != tt.want {
+			t.Errorf("Get(%q) = %q, ожидается %q", tt.key, got, tt.want)
+		}
+	}
+
+	limits, err := orchestrator.LoadLimits(source.Get)
+	if err != nil || limits.MaxOperators != 50 || limits.MaxBatchSize != 7 || limits.CacheTTL != 5*time.Minute {
+		t.Errorf("LoadLimits() = %+v, %v", limits, err)
+	}
+}
+
+func TestConfigLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := config.Load(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Load() для отсутствующего файла должен вернуть ошибку")
+	}
+
+	path := filepath.Join(dir, "config.json")
+	writeConfig(t, path, `{"MAX_OPERATORS": [1, 2]}`)
+	if _, err := config.Load(path); err == nil || !strings.Contains(err.Error(), "MAX_OPERATORS") {
+		t.Errorf("Load() error = %v, ожидается ошибка с именем настройки", err)
+	}
+
+	writeConfig(t, path, `{"MAX_OPERATORS": "many", "MAX_WAIT": "soon", "TIME_DIVISIONS_MS": -1, "TIME_ADDITION_MS": 86400001, "WEBHOOK_MAX_ATTEMPTS": 0, "WEBHOOK_ALLOWED_NETWORKS": "10.0.0.0"}`)
+	source, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// Ошибки всех настроек сообщаются разом, а не только первая
+	_, limitsErr := orchestrator.LoadLimits(source.Get)
+	_, timesErr := orchestrator.LoadOperationTimes(source.Get)
+	_, webhookErr := orchestrator.LoadWebhookConfig(source.Get)
+	for _, tt := range []struct {
+		err  error
+		keys []string
+	}{
+		{limitsErr, []string{"MAX_OPERATORS", "MAX_WAIT"}},
+		{timesErr, []string{"TIME_DIVISIONS_MS", "TIME_ADDITION_MS"}},
+		{webhookErr, []string{"WEBHOOK_MAX_ATTEMPTS", "WEBHOOK_ALLOWED_NETWORKS"}},
+	} {
+		for _, key := range tt.keys {
+			if tt.err == nil || !strings.Contains(tt.err.Error(), key) {
+				t.Errorf("ошибка = %v, ожидается упоминание %s", tt.err, key)
+			}
+		}
+	}
+}
+
+func TestConfigWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, `{"COMPUTING_POWER": 2}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reloads := make(chan string, 10)
+	done := make(chan struct{})
+	go func() {
+		config.Watch(ctx, path, 10*time.Millisecond, func() {
+			source, _ := config.Load(path)
+			reloads <- source.Get("COMPUTING_POWER")
+		})
+		close(done)
+	}()
+
+	time.Sleep(30 * time.Millisecond)
+	writeConfig(t, path, `{"COMPUTING_POWER": 16}`)
+
+	select {
+	case got := <-reloads:
+		if got != "16" {
+			t.Errorf("После изменения файла COMPUTING_POWER = %q, ожидается 16", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Изменение файла не вызвало перечитывание настроек")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Watch не завершился после отмены контекста")
+	}
+}
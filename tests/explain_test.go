@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"calculator-service/internal/orchestrator"
+	"calculator-service/internal/types"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func explain(t *testing.T, expression, query string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleExplain(w, httptest.NewRequest(http.MethodPost, "/api/v1/explain"+query,
+		strings.NewReader(`{"expression": "`+expression+`"}`)))
+	return w
+}
+
+func explainPlan(t *testing.T, expression string) types.Plan {
+	t.Helper()
+
+	w := explain(t, expression, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("HandleExplain() код статуса = %v, ожидается %v: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var plan types.Plan
+	if err := json.Unmarshal(w.Body.Bytes(), &plan); err != nil {
+		t.Fatalf("Невозможно распарсить ответ: %v", err)
+	}
+	return plan
+}
+
+func registerAgent(id string, capacity string) {
+	registerAgentWith(id, capacity, "")
+}
+
+func registerAgentWith(id, capacity, capabilities string) {
+	req := httptest.NewRequest(http.MethodGet, "/internal/task", nil)
+	req.Header.Set(types.AgentIDHeader, id)
+	req.Header.Set(types.AgentCapacityHeader, capacity)
+	if capabilities != "" {
+		req.Header.Set(types.AgentCapabilitiesHeader, capabilities)
+	}
+	orchestrator.HandleGetTask(httptest.NewRecorder(), req)
+}
+
+func TestExplain(t *testing.T) {
+	setupTest()
+	orchestrator.SetOperationTimes(orchestrator.OperationTimes{
+		Addition:       100 * time.Millisecond,
+		Subtraction:    100 * time.Millisecond,
+		Multiplication: 300 * time.Millisecond,
+		Division:       200 * time.Millisecond,
+	})
+	defer orchestrator.SetOperationTimes(orchestrator.DefaultOperationTimes())
+
+	// (1+2) и (2+1) - одна задача, 4/2 и 5*6 выполняются параллельно с ней
+	const expression = "(1+2)*(2+1) - 4/2 - 5*6"
+
+	plan := explainPlan(t, expression)
+	if len(plan.Tasks) != 6 || plan.Depth != 4 || plan.ReusedOperations != 1 {
+		t.Fatalf("План = %+v, ожидается 6 задач в 4 этапа и 1 переиспользованная операция", plan)
+	}
+	// + (100) -> * (300) -> - (100) -> - (100)
+	if plan.CriticalPathMs != 600 {
+		t.Errorf("critical_path_ms = %d, ожидается 600", plan.CriticalPathMs)
+	}
+	if plan.Workers != 0 || plan.EstimatedMs != nil {
+		t.Errorf("Без агентов workers = %d, estimated_ms = %v, ожидается 0 и нет оценки", plan.Workers, plan.EstimatedMs)
+	}
+
+	var critical []string
+	for _, task := range plan.Tasks {
+		if task.Critical {
+			critical = append(critical, task.Operation)
+		}
+	}
+	if got := strings.Join(critical, " "); got != "+ * - -" {
+		t.Errorf("Критический путь = %q, ожидается \"+ * - -\"", got)
+	}
+
+	if w := explain(t, expression, "?format=dot"); w.Code != http.StatusOK ||
+		!strings.HasPrefix(w.Body.String(), "digraph plan {") || !strings.Contains(w.Body.String(), "t1 -> t2;") {
+		t.Errorf("DOT = %s, ожидается граф с ребром t1 -> t2", w.Body.String())
+	}
+
+	// План не создаёт задач
+	w := httptest.NewRecorder()
+	orchestrator.HandleGetTask(w, httptest.NewRequest(http.MethodGet, "/internal/task", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("HandleGetTask() код статуса = %v, ожидается %v", w.Code, http.StatusNoContent)
+	}
+}
+
+func TestExplainEstimate(t *testing.T) {
+	setupTest()
+	defer orchestrator.SetOperationTimes(orchestrator.DefaultOperationTimes())
+	orchestrator.SetOperationTimes(orchestrator.OperationTimes{
+		Addition:       100 * time.Millisecond,
+		Subtraction:    100 * time.Millisecond,
+		Multiplication: 100 * time.Millisecond,
+		Division:       100 * time.Millisecond,
+	})
+
+	// Четыре независимых сложения и три задачи, собирающие их результаты
+	const expression = "((1+2)*(3+4))*((5+6)*(7+8))"
+
+	registerAgent("agent-a", "1")
+	if plan := explainPlan(t, expression); plan.Workers != 1 || plan.EstimatedMs == nil || *plan.EstimatedMs != 700 {
+		t.Errorf("На одном агенте workers = %d, estimated_ms = %v, ожидается 1 и 700", plan.Workers, plan.EstimatedMs)
+	}
+
+	registerAgent("agent-b", "3")
+	plan := explainPlan(t, expression)
+	if plan.Workers != 4 || plan.EstimatedMs == nil || *plan.EstimatedMs != plan.CriticalPathMs || plan.CriticalPathMs != 300 {
+		t.Errorf("На четырёх агентах workers = %d, estimated_ms = %v, critical_path_ms = %d, ожидается 4, 300 и 300",
+			plan.Workers, plan.EstimatedMs, plan.CriticalPathMs)
+	}
+}
+
+// Оценка выдаёт задачи только агентам, которые могут их выполнить
+func TestExplainEstimateCapabilities(t *testing.T) {
+	setupTest()
+	defer orchestrator.SetOperationTimes(orchestrator.DefaultOperationTimes())
+	orchestrator.SetOperationTimes(orchestrator.OperationTimes{
+		Addition:       100 * time.Millisecond,
+		Subtraction:    100 * time.Millisecond,
+		Multiplication: 100 * time.Millisecond,
+		Division:       100 * time.Millisecond,
+	})
+
+	const expression = "(1+2)*(3+4)"
+
+	registerAgentWith("adder", "1", "+,-")
+	if plan := explainPlan(t, expression); plan.Workers != 1 || plan.EstimatedMs != nil {
+		t.Errorf("Без агента для умножения workers = %d, estimated_ms = %v, ожидается 1 и нет оценки", plan.Workers, plan.EstimatedMs)
+	}
+
+	// Сложения выполняются по очереди на единственном агенте со сложением, хотя мест четыре
+	registerAgentWith("multiplier", "3", "*,/")
+	if plan := explainPlan(t, expression); plan.Workers != 4 || plan.EstimatedMs == nil || *plan.EstimatedMs != 300 {
+		t.Errorf("workers = %d, estimated_ms = %v, ожидается 4 и 300", plan.Workers, plan.EstimatedMs)
+	}
+}
+
+// План учитывает поддеревья, которые уже вычисляются для других выражений
+func TestExplainInFlight(t *testing.T) {
+	setupTest()
+
+	submitExpression(t, "7*8")
+
+	plan := explainPlan(t, "7*8+1")
+	if len(plan.Tasks) != 2 || plan.Tasks[0].Source != types.PlanTaskInFlight || plan.Tasks[1].Arg1Task != plan.Tasks[0].ID {
+		t.Errorf("План = %+v, ожидается задача 7*8 из другого выражения", plan.Tasks)
+	}
+	if plan.ReusedOperations != 1 {
+		t.Errorf("reused_operations = %d, ожидается 1", plan.ReusedOperations)
+	}
+}
+
+func TestExplainErrors(t *testing.T) {
+	setupTest()
+
+	tests := []struct {
+		name       string
+		expression string
+		query      string
+		wantStatus int
+	}{
+		{"некорректное выражение", "2+a", "", http.StatusUnprocessableEntity},
+		{"деление на ноль", "1/0", "", http.StatusUnprocessableEntity},
+		{"неизвестный формат", "1+2", "?format=png", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := explain(t, tt.expression, tt.query); w.Code != tt.wantStatus {
+				t.Errorf("код статуса = %v, ожидается %v", w.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	if plan := explainPlan(t, "42"); len(plan.Tasks) != 0 || plan.Depth != 0 || plan.CriticalPathMs != 0 {
+		t.Errorf("План числа = %+v, ожидается пустой", plan)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -334,3 +335,218 @@ func TestOrderOfOperations(t *testing.T) {
 		})
 	}
 }
+
+// listAll обходит все страницы списка выражений
+func listAll(t *testing.T, query string) []types.Expression {
+	t.Helper()
+
+	var collected []types.Expression
+	cursor := ""
+	for {
+		url := "/api/v1/expressions?" + query
+		if cursor != "" {
+			url += "&cursor=" + cursor
+		}
+		w := httptest.NewRecorder()
+		orchestrator.HandleGetExpressions(w, httptest.NewRequest(http.MethodGet, url, nil))
+		var response types.ExpressionResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Невозможно распарсить ответ: %v", err)
+		}
+		collected = append(collected, response.Expressions...)
+		if cursor = response.NextCursor; cursor == "" {
+			return collected
+		}
+	}
+}
+
+func TestHandleGetExpressionsSortByCompletedAt(t *testing.T) {
+	setupTest()
+	processingID := submitExpression(t, "1+1")
+	submitExpression(t, "3")
+	submitExpression(t, "5")
+
+	finished := listAll(t, "sort=completed_at&order=asc&limit=1")
+	if len(finished) != 2 {
+		t.Fatalf("Получено %d выражений, ожидается 2 завершённых", len(finished))
+	}
+	for _, expr := range finished {
+		if expr.ID == processingID || expr.CompletedAt == nil {
+			t.Errorf("В списке по completed_at незавершённое выражение %+v", expr)
+		}
+	}
+
+	// Выражение появляется в конце списка после завершения
+	completeAllTasks()
+	finished = listAll(t, "sort=completed_at&order=asc&limit=1")
+	if len(finished) != 3 || finished[2].ID != processingID {
+		t.Errorf("Получено %d выражений, ожидается 3 с завершившимся последним", len(finished))
+	}
+}
+
+func TestHandleGetExpressionsPagination(t *testing.T) {
+	setupTest()
+
+	for _, expression := range []string{"1+1", "2+2", "3", "4*4", "5"} {
+		calcReq := httptest.NewRequest(http.MethodPost, "/api/v1/calculate",
+			strings.NewReader(`{"expression": "`+expression+`"}`))
+		calcW := httptest.NewRecorder()
+		orchestrator.HandleCalculate(calcW, calcReq)
+	}
+
+	var collected []types.Expression
+	cursor := ""
+	for page := 0; page < 5; page++ {
+		url := "/api/v1/expressions?limit=2&order=asc"
+		if cursor != "" {
+			url += "&cursor=" + cursor
+		}
+		w := httptest.NewRecorder()
+		orchestrator.HandleGetExpressions(w, httptest.NewRequest(http.MethodGet, url, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("HandleGetExpressions() код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+		}
+
+		var response types.ExpressionResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Невозможно распарсить ответ: %v", err)
+		}
+		if len(response.Expressions) > 2 {
+			t.Fatalf("Страница содержит %d элементов, ожидается не больше 2", len(response.Expressions))
+		}
+
+		collected = append(collected, response.Expressions...)
+		cursor = response.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+
+	if len(collected) != 5 {
+		t.Fatalf("Получено %d выражений, ожидается 5", len(collected))
+	}
+	for i := 1; i < len(collected); i++ {
+		if collected[i].CreatedAt.Before(collected[i-1].CreatedAt) {
+			t.Errorf("Выражения не отсортированы по created_at: %v", collected)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleGetExpressions(w, httptest.NewRequest(http.MethodGet, "/api/v1/expressions?status=COMPLETED", nil))
+
+	var completed types.ExpressionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &completed); err != nil {
+		t.Fatalf("Невозможно распарсить ответ: %v", err)
+	}
+	if len(completed.Expressions) != 2 {
+		t.Errorf("Фильтр по статусу вернул %d выражений, ожидается 2", len(completed.Expressions))
+	}
+	for _, expr := range completed.Expressions {
+		if expr.CompletedAt == nil {
+			t.Errorf("У завершённого выражения %s нет completed_at", expr.ID)
+		}
+	}
+
+	for _, query := range []string{"status=UNKNOWN", "limit=0", "sort=id", "cursor=???", "created_from=yesterday"} {
+		w := httptest.NewRecorder()
+		orchestrator.HandleGetExpressions(w, httptest.NewRequest(http.MethodGet, "/api/v1/expressions?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Запрос %q: код статуса = %v, ожидается %v", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+// Курсор привязан к полю и направлению сортировки страницы, с которой он получен
+func TestHandleGetExpressionsCursorMismatch(t *testing.T) {
+	setupTest()
+	for _, expression := range []string{"1", "2", "3"} {
+		submitExpression(t, expression)
+	}
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleGetExpressions(w, httptest.NewRequest(http.MethodGet, "/api/v1/expressions?order=asc&limit=1", nil))
+	var response types.ExpressionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.NextCursor == "" {
+		t.Fatalf("Нет next_cursor в ответе %s", w.Body.String())
+	}
+
+	tests := []struct {
+		query      string
+		wantStatus int
+	}{
+		{"order=asc", http.StatusOK},
+		{"order=desc", http.StatusBadRequest},
+		{"", http.StatusBadRequest},
+		{"order=asc&sort=completed_at", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		url := "/api/v1/expressions?limit=1&cursor=" + response.NextCursor + "&" + tt.query
+		orchestrator.HandleGetExpressions(w, httptest.NewRequest(http.MethodGet, url, nil))
+		if w.Code != tt.wantStatus {
+			t.Errorf("Курсор страницы order=asc с %q: код статуса = %v, ожидается %v", tt.query, w.Code, tt.wantStatus)
+		}
+	}
+}
+
+func TestExpressionTimings(t *testing.T) {
+	setupTest()
+
+	calcReq := httptest.NewRequest(http.MethodPost, "/api/v1/calculate",
+		strings.NewReader(`{"expression": "2*3"}`))
+	calcW := httptest.NewRecorder()
+	orchestrator.HandleCalculate(calcW, calcReq)
+
+	var calcResponse map[string]string
+	if err := json.Unmarshal(calcW.Body.Bytes(), &calcResponse); err != nil {
+		t.Fatalf("Невозможно распарсить ответ: %v", err)
+	}
+	exprID := calcResponse["id"]
+
+	time.Sleep(30 * time.Millisecond)
+
+	taskW := httptest.NewRecorder()
+	orchestrator.HandleGetTask(taskW, httptest.NewRequest(http.MethodGet, "/internal/task", nil))
+
+	var task types.Task
+	if err := json.Unmarshal(taskW.Body.Bytes(), &task); err != nil {
+		t.Fatalf("Невозможно распарсить ответ: %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	resultBody, _ := json.Marshal(types.TaskResult{ID: task.ID, Result: 6})
+	resultW := httptest.NewRecorder()
+	orchestrator.HandleSubmitTaskResult(resultW, httptest.NewRequest(http.MethodPost, "/internal/task", bytes.NewReader(resultBody)))
+
+	exprReq := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/expressions/"+exprID, nil), map[string]string{"id": exprID})
+	exprW := httptest.NewRecorder()
+	orchestrator.HandleGetExpression(exprW, exprReq)
+
+	var expr types.Expression
+	if err := json.Unmarshal(exprW.Body.Bytes(), &expr); err != nil {
+		t.Fatalf("Невозможно распарсить ответ: %v", err)
+	}
+
+	if expr.StartedAt == nil || expr.CompletedAt == nil {
+		t.Fatalf("Ожидаются started_at и completed_at, получено %+v", expr)
+	}
+	if expr.StartedAt.Before(expr.CreatedAt) || expr.CompletedAt.Before(*expr.StartedAt) {
+		t.Errorf("Нарушен порядок временных меток: %+v", expr)
+	}
+	if expr.Metrics == nil {
+		t.Fatalf("Ожидаются метрики выражения")
+	}
+	if expr.Metrics.Tasks != 1 {
+		t.Errorf("metrics.tasks = %d, ожидается 1", expr.Metrics.Tasks)
+	}
+	if expr.Metrics.QueueWaitMs < 30 {
+		t.Errorf("metrics.queue_wait_ms = %d, ожидается не меньше 30", expr.Metrics.QueueWaitMs)
+	}
+	if expr.Metrics.ComputeTimeMs < 30 {
+		t.Errorf("metrics.compute_time_ms = %d, ожидается не меньше 30", expr.Metrics.ComputeTimeMs)
+	}
+	if expr.Metrics.WallTimeMs < expr.Metrics.QueueWaitMs+expr.Metrics.ComputeTimeMs {
+		t.Errorf("metrics.wall_time_ms = %d меньше суммы ожидания и вычисления", expr.Metrics.WallTimeMs)
+	}
+}
@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"calculator-service/internal/orchestrator"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func calculateWithKey(key, expression string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate",
+		strings.NewReader(`{"expression": "`+expression+`"}`))
+	req.Header.Set(orchestrator.IdempotencyKeyHeader, key)
+	w := httptest.NewRecorder()
+	orchestrator.HandleCalculate(w, req)
+	return w
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	setupTest()
+
+	first := calculateWithKey("retry-1", "2+3")
+	if first.Code != http.StatusOK {
+		t.Fatalf("HandleCalculate() код статуса = %v, ожидается %v", first.Code, http.StatusOK)
+	}
+	var original map[string]string
+	json.Unmarshal(first.Body.Bytes(), &original)
+
+	t.Run("повтор с тем же телом", func(t *testing.T) {
+		w := calculateWithKey("retry-1", "2+3")
+		if w.Code != http.StatusOK {
+			t.Fatalf("HandleCalculate() код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+		}
+
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+		if response["id"] != original["id"] {
+			t.Errorf("Повтор вернул ID %s, ожидается исходный %s", response["id"], original["id"])
+		}
+		if w.Header().Get("Idempotent-Replayed") != "true" {
+			t.Errorf("Ожидается заголовок Idempotent-Replayed: true")
+		}
+	})
+
+	t.Run("тот же ключ с другим телом", func(t *testing.T) {
+		w := calculateWithKey("retry-1", "2+4")
+		if w.Code != http.StatusConflict {
+			t.Errorf("HandleCalculate() код статуса = %v, ожидается %v", w.Code, http.StatusConflict)
+		}
+	})
+
+	t.Run("другой ключ", func(t *testing.T) {
+		w := calculateWithKey("retry-2", "2+3")
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+		if response["id"] == "" || response["id"] == original["id"] {
+			t.Errorf("Новый ключ должен создать новое выражение, получен ID %q", response["id"])
+		}
+	})
+
+	t.Run("ошибка не запоминается", func(t *testing.T) {
+		if w := calculateWithKey("retry-3", "2+"); w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("HandleCalculate() код статуса = %v, ожидается %v", w.Code, http.StatusUnprocessableEntity)
+		}
+		if w := calculateWithKey("retry-3", "2+5"); w.Code != http.StatusOK {
+			t.Errorf("После ошибки ключ должен быть свободен, код статуса = %v", w.Code)
+		}
+	})
+
+	t.Run("некорректный ключ", func(t *testing.T) {
+		if w := calculateWithKey("key with spaces", "2+3"); w.Code != http.StatusBadRequest {
+			t.Errorf("HandleCalculate() код статуса = %v, ожидается %v", w.Code, http.StatusBadRequest)
+		}
+	})
+}
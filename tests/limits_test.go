@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"calculator-service/internal/orchestrator"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExpressionSizeLimits(t *testing.T) {
+	setupTest()
+
+	limits := orchestrator.DefaultLimits()
+	limits.MaxBodyBytes = 200
+	limits.MaxExpressionLength = 50
+	limits.MaxOperators = 3
+	limits.MaxNestingDepth = 2
+	orchestrator.SetLimits(limits)
+	defer orchestrator.SetLimits(orchestrator.DefaultLimits())
+
+	tests := []struct {
+		name       string
+		expression string
+		wantStatus int
+	}{
+		{"в пределах ограничений", "(1+2)*3", http.StatusOK},
+		{"слишком большое тело", strings.Repeat("1", 300), http.StatusRequestEntityTooLarge},
+		{"слишком длинное выражение", strings.Repeat("1", 60), http.StatusUnprocessableEntity},
+		{"слишком много операторов", "1+1+1+1+1", http.StatusUnprocessableEntity},
+		{"слишком глубокая вложенность", "(((1+2)))", http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate",
+				strings.NewReader(`{"expression": "`+tt.expression+`"}`))
+			w := httptest.NewRecorder()
+			orchestrator.HandleCalculate(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("HandleCalculate() код статуса = %v, ожидается %v: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestMaxPendingExpressions(t *testing.T) {
+	setupTest()
+
+	limits := orchestrator.DefaultLimits()
+	limits.MaxPending = 2
+	orchestrator.SetLimits(limits)
+	defer orchestrator.SetLimits(orchestrator.DefaultLimits())
+
+	codes := make([]int, 0, 4)
+	for _, expression := range []string{"1+1", "2+2", "3", "4+4"} {
+		w := httptest.NewRecorder()
+		orchestrator.HandleCalculate(w, httptest.NewRequest(http.MethodPost, "/api/v1/calculate",
+			strings.NewReader(`{"expression": "`+expression+`"}`)))
+		codes = append(codes, w.Code)
+	}
+
+	want := []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Errorf("Запрос %d: код статуса = %v, ожидается %v", i+1, codes[i], want[i])
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	setupTest()
+
+	limits := orchestrator.DefaultLimits()
+	limits.RateLimitRPS = 1
+	limits.RateLimitBurst = 2
+	orchestrator.SetLimits(limits)
+	defer orchestrator.SetLimits(orchestrator.DefaultLimits())
+
+	handler := orchestrator.RateLimit(http.HandlerFunc(orchestrator.HandleCalculate))
+
+	var last *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		last = httptest.NewRecorder()
+		handler.ServeHTTP(last, httptest.NewRequest(http.MethodPost, "/api/v1/calculate",
+			strings.NewReader(`{"expression": "2"}`)))
+		if i < 2 && last.Code != http.StatusOK {
+			t.Fatalf("Запрос %d в пределах burst: код статуса = %v", i+1, last.Code)
+		}
+	}
+
+	if last.Code != http.StatusTooManyRequests {
+		t.Fatalf("Запрос сверх лимита: код статуса = %v, ожидается %v", last.Code, http.StatusTooManyRequests)
+	}
+	if last.Header().Get("Retry-After") != "1" {
+		t.Errorf("Retry-After = %q, ожидается 1", last.Header().Get("Retry-After"))
+	}
+
+	other := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(`{"expression": "2"}`))
+	other.RemoteAddr = "198.51.100.7:1234"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, other)
+	if w.Code != http.StatusOK {
+		t.Errorf("Другой клиент: код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"bytes"
+	"calculator-service/internal/logging"
+	"calculator-service/internal/orchestrator"
+	"calculator-service/internal/types"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLoggerContextAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "info", "json")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+	ctx = logging.With(ctx, "expression_id", "expr-1", "task_id", "task-1")
+	logger.DebugContext(ctx, "skipped")
+	logger.InfoContext(ctx, "task dispatched")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Ожидается одна JSON-запись, получено %q: %v", buf.String(), err)
+	}
+
+	want := map[string]string{
+		"msg":           "task dispatched",
+		"request_id":    "req-1",
+		"expression_id": "expr-1",
+		"task_id":       "task-1",
+	}
+	for key, value := range want {
+		if record[key] != value {
+			t.Errorf("Поле %s = %v, ожидается %v", key, record[key], value)
+		}
+	}
+}
+
+func TestLoggerInvalidConfig(t *testing.T) {
+	if _, err := logging.New(&bytes.Buffer{}, "verbose", "json"); err == nil {
+		t.Errorf("New() с неизвестным уровнем должен вернуть ошибку")
+	}
+	if _, err := logging.New(&bytes.Buffer{}, "info", "xml"); err == nil {
+		t.Errorf("New() с неизвестным форматом должен вернуть ошибку")
+	}
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	setupTest()
+
+	handler := logging.Middleware(http.HandlerFunc(orchestrator.HandleCalculate))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(`{"expression": "2*3"}`))
+	req.Header.Set(logging.RequestIDHeader, "client-request")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got := w.Header().Get(logging.RequestIDHeader); got != "client-request" {
+		t.Errorf("Заголовок %s = %q, ожидается client-request", logging.RequestIDHeader, got)
+	}
+
+	taskW := httptest.NewRecorder()
+	orchestrator.HandleGetTask(taskW, httptest.NewRequest(http.MethodGet, "/internal/task", nil))
+
+	var task types.Task
+	if err := json.Unmarshal(taskW.Body.Bytes(), &task); err != nil {
+		t.Fatalf("Невозможно распарсить ответ: %v", err)
+	}
+	if task.RequestID != "client-request" {
+		t.Errorf("task.request_id = %q, ожидается client-request", task.RequestID)
+	}
+	if task.ExpressionID == "" {
+		t.Errorf("У задачи не заполнен expression_id")
+	}
+}
+
+// У пакета результатов свой X-Request-ID, но записи о каждом результате несут request_id выражения
+func TestRequestIDInBatchResults(t *testing.T) {
+	setupTest()
+
+	var buf bytes.Buffer
+	logger, _ := logging.New(&buf, "debug", "json")
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(logger)
+
+	calculate := logging.Middleware(http.HandlerFunc(orchestrator.HandleCalculate))
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(`{"expression": "2*3"}`))
+	req.Header.Set(logging.RequestIDHeader, "client-request")
+	calculate.ServeHTTP(httptest.NewRecorder(), req)
+
+	_, tasks := getTasks(t, "?max=10")
+	if len(tasks) != 1 {
+		t.Fatalf("задач = %d, ожидается 1", len(tasks))
+	}
+
+	body, _ := json.Marshal(types.TaskResultBatch{Results: []types.TaskResult{{ID: tasks[0].ID, Result: 6}}})
+	req = httptest.NewRequest(http.MethodPost, "/internal/tasks", bytes.NewReader(body))
+	req.Header.Set(types.ProtocolVersionHeader, strconv.Itoa(types.ProtocolV4))
+	req.Header.Set(logging.RequestIDHeader, "agent-batch")
+	logging.Middleware(http.HandlerFunc(orchestrator.HandleSubmitTaskResults)).ServeHTTP(httptest.NewRecorder(), req)
+
+	found := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			continue
+		}
+		msg, _ := record["msg"].(string)
+		if msg != "task result received" && msg != "expression completed" {
+			continue
+		}
+		found[msg] = true
+		if record["request_id"] != "client-request" {
+			t.Errorf("%q: request_id = %v, ожидается client-request", msg, record["request_id"])
+		}
+	}
+	if !found["task result received"] || !found["expression completed"] {
+		t.Errorf("В логах нет записей о приёме результата: %s", buf.String())
+	}
+}
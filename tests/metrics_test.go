@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"bytes"
+	"calculator-service/internal/metrics"
+	"calculator-service/internal/orchestrator"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryExposition(t *testing.T) {
+	registry := metrics.NewRegistry()
+
+	counter := registry.NewCounterVec("test_requests_total", "Requests.", "code")
+	counter.Inc("200")
+	counter.Add(2, "500")
+
+	histogram := registry.NewHistogramVec("test_latency_seconds", "Latency.", []float64{0.1, 1}, "op")
+	histogram.Observe(0.05, "+")
+	histogram.Observe(0.5, "+")
+
+	registry.NewGaugeFunc("test_items", "Items.", "state", func() map[string]float64 {
+		return map[string]float64{"ready": 3}
+	})
+
+	var buf bytes.Buffer
+	registry.Expose(&buf)
+	output := buf.String()
+
+	expected := []string{
+		"# TYPE test_requests_total counter",
+		`test_requests_total{code="200"} 1`,
+		`test_requests_total{code="500"} 2`,
+		"# TYPE test_latency_seconds histogram",
+		`test_latency_seconds_bucket{op="+",le="0.1"} 1`,
+		`test_latency_seconds_bucket{op="+",le="1"} 2`,
+		`test_latency_seconds_bucket{op="+",le="+Inf"} 2`,
+		`test_latency_seconds_sum{op="+"} 0.55`,
+		`test_latency_seconds_count{op="+"} 2`,
+		`test_items{state="ready"} 3`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("В выводе метрик нет строки %q:\n%s", line, output)
+		}
+	}
+}
+
+func TestHandleMetrics(t *testing.T) {
+	setupTest()
+
+	calcReq := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(`{"expression": "2+2*2"}`))
+	orchestrator.HandleCalculate(httptest.NewRecorder(), calcReq)
+
+	orchestrator.HandleGetTask(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/internal/task", nil))
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("HandleMetrics() код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+	}
+
+	output := w.Body.String()
+	expected := []string{
+		`calc_expressions{status="PROCESSING"} 1`,
+		`calc_task_queue_depth{state="leased"} 1`,
+		`calc_task_queue_depth{state="blocked"} 1`,
+		`calc_task_dispatch_latency_seconds_count{operation="*"}`,
+		"# TYPE calc_task_results_total counter",
+	}
+	for _, line := range expected {
+		if !strings.Contains(output, line) {
+			t.Errorf("В выводе метрик нет %q:\n%s", line, output)
+		}
+	}
+}
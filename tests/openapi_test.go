@@ -0,0 +1,216 @@
+package tests
+
+import (
+	"bytes"
+	"calculator-service/internal/auth"
+	"calculator-service/internal/openapi"
+	"calculator-service/internal/orchestrator"
+	"calculator-service/internal/types"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+type specCase struct {
+	name    string
+	method  string
+	path    string // шаблон пути из спецификации
+	handler http.Handler
+	request func() *http.Request
+}
+
+func checkSpec(t *testing.T, spec []byte, cases []specCase) {
+	t.Helper()
+
+	doc, err := openapi.Parse(spec)
+	if err != nil {
+		t.Fatalf("Невозможно распарсить спецификацию: %v", err)
+	}
+
+	covered := make(map[string]bool)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tc.handler.ServeHTTP(w, tc.request())
+
+			if err := doc.ValidateResponse(tc.method, tc.path, w.Code, w.Header(), w.Body.Bytes()); err != nil {
+				t.Errorf("Ответ не соответствует спецификации: %v\n%s", err, w.Body.String())
+			}
+		})
+		covered[tc.method+" "+tc.path] = true
+	}
+
+	for _, op := range doc.Operations() {
+		if !covered[op] {
+			t.Errorf("Операция %s не проверяется тестом", op)
+		}
+	}
+}
+
+func jsonRequest(method, target, body string) func() *http.Request {
+	return func() *http.Request {
+		return httptest.NewRequest(method, target, strings.NewReader(body))
+	}
+}
+
+// agentRequest - запрос агента текущей версии протокола
+func agentRequest(method, target, body string) func() *http.Request {
+	return func() *http.Request {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(types.ProtocolVersionHeader, strconv.Itoa(types.ProtocolVersion))
+		return req
+	}
+}
+
+func withID(method, target, id string) func() *http.Request {
+	return func() *http.Request {
+		return mux.SetURLVars(httptest.NewRequest(method, target, nil), map[string]string{"id": id})
+	}
+}
+
+func TestOrchestratorOpenAPI(t *testing.T) {
+	setupTest()
+
+	loginUser(t, "spec-user")
+	completedID := submitExpression(t, "1+2")
+	completeAllTasks()
+	processingID := submitExpression(t, "3*4")
+	cancelID := submitExpression(t, "7*7")
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleCalculateBatch(w, httptest.NewRequest(http.MethodPost, "/api/v1/calculate/batch",
+		strings.NewReader(`{"expressions": [{"key": "a", "expression": "2+2"}]}`)))
+	var batch map[string]any
+	json.Unmarshal(w.Body.Bytes(), &batch)
+	batchID, _ := batch["id"].(string)
+
+	protected := auth.RequireUser(http.HandlerFunc(orchestrator.HandleGetExpressions))
+	secret := auth.RequireAgentSecret("agent-secret")(http.HandlerFunc(orchestrator.HandleGetTask))
+	admin := auth.RequireAdminToken("admin-token")(http.HandlerFunc(orchestrator.HandleGetOperationTimes))
+	defer orchestrator.SetOperationTimes(orchestrator.DefaultOperationTimes())
+
+	checkSpec(t, openapi.Orchestrator, []specCase{
+		{"регистрация", "POST", "/api/v1/register", http.HandlerFunc(auth.HandleRegister),
+			jsonRequest("POST", "/api/v1/register", `{"login": "spec-new", "password": "secret-password"}`)},
+		{"повторная регистрация", "POST", "/api/v1/register", http.HandlerFunc(auth.HandleRegister),
+			jsonRequest("POST", "/api/v1/register", `{"login": "spec-user", "password": "secret-password"}`)},
+		{"вход", "POST", "/api/v1/login", http.HandlerFunc(auth.HandleLogin),
+			jsonRequest("POST", "/api/v1/login", `{"login": "spec-user", "password": "secret-password"}`)},
+		{"неверный пароль", "POST", "/api/v1/login", http.HandlerFunc(auth.HandleLogin),
+			jsonRequest("POST", "/api/v1/login", `{"login": "spec-user", "password": "wrong-password"}`)},
+		{"отправка выражения", "POST", "/api/v1/calculate", http.HandlerFunc(orchestrator.HandleCalculate),
+			jsonRequest("POST", "/api/v1/calculate", `{"expression": "5-1"}`)},
+		{"отправка с ожиданием", "POST", "/api/v1/calculate", http.HandlerFunc(orchestrator.HandleCalculate),
+			jsonRequest("POST", "/api/v1/calculate?wait=1s", `{"expression": "8"}`)},
+		{"ожидание истекло", "POST", "/api/v1/calculate", http.HandlerFunc(orchestrator.HandleCalculate),
+			jsonRequest("POST", "/api/v1/calculate?wait=10ms", `{"expression": "9-1"}`)},
+		{"некорректное выражение", "POST", "/api/v1/calculate", http.HandlerFunc(orchestrator.HandleCalculate),
+			jsonRequest("POST", "/api/v1/calculate", `{"expression": "2+"}`)},
+		{"ошибка обработки выражения", "POST", "/api/v1/calculate", http.HandlerFunc(orchestrator.HandleCalculate),
+			jsonRequest("POST", "/api/v1/calculate", `{"expression": "1.2.3+1"}`)},
+		{"локальное вычисление", "POST", "/api/v1/evaluate", http.HandlerFunc(orchestrator.HandleEvaluate),
+			jsonRequest("POST", "/api/v1/evaluate", `{"expression": "2+2*2"}`)},
+		{"локальное вычисление некорректного выражения", "POST", "/api/v1/evaluate", http.HandlerFunc(orchestrator.HandleEvaluate),
+			jsonRequest("POST", "/api/v1/evaluate", `{"expression": "2+a"}`)},
+		{"ошибка локального вычисления", "POST", "/api/v1/evaluate", http.HandlerFunc(orchestrator.HandleEvaluate),
+			jsonRequest("POST", "/api/v1/evaluate", `{"expression": "1.2.3"}`)},
+		{"локальное вычисление без тела", "POST", "/api/v1/evaluate", http.HandlerFunc(orchestrator.HandleEvaluate),
+			func() *http.Request { return httptest.NewRequest("POST", "/api/v1/evaluate", bytes.NewReader(nil)) }},
+		{"план выполнения", "POST", "/api/v1/explain", http.HandlerFunc(orchestrator.HandleExplain),
+			jsonRequest("POST", "/api/v1/explain", `{"expression": "(1+2)*(2+1)-3/4"}`)},
+		{"план выполнения в DOT", "POST", "/api/v1/explain", http.HandlerFunc(orchestrator.HandleExplain),
+			jsonRequest("POST", "/api/v1/explain?format=dot", `{"expression": "1+2"}`)},
+		{"план с неизвестным форматом", "POST", "/api/v1/explain", http.HandlerFunc(orchestrator.HandleExplain),
+			jsonRequest("POST", "/api/v1/explain?format=svg", `{"expression": "1+2"}`)},
+		{"ошибка построения плана", "POST", "/api/v1/explain", http.HandlerFunc(orchestrator.HandleExplain),
+			jsonRequest("POST", "/api/v1/explain", `{"expression": "1..2*3"}`)},
+		{"пакет", "POST", "/api/v1/calculate/batch", http.HandlerFunc(orchestrator.HandleCalculateBatch),
+			jsonRequest("POST", "/api/v1/calculate/batch", `{"expressions": [{"expression": "1*2"}, {"expression": "2+a"}]}`)},
+		{"состояние пакета", "GET", "/api/v1/batches/{id}", http.HandlerFunc(orchestrator.HandleGetBatch),
+			withID("GET", "/api/v1/batches/"+batchID, batchID)},
+		{"пакет не найден", "GET", "/api/v1/batches/{id}", http.HandlerFunc(orchestrator.HandleGetBatch),
+			withID("GET", "/api/v1/batches/missing", "missing")},
+		{"список выражений", "GET", "/api/v1/expressions", http.HandlerFunc(orchestrator.HandleGetExpressions),
+			jsonRequest("GET", "/api/v1/expressions?limit=1", "")},
+		{"некорректный фильтр", "GET", "/api/v1/expressions", http.HandlerFunc(orchestrator.HandleGetExpressions),
+			jsonRequest("GET", "/api/v1/expressions?status=UNKNOWN", "")},
+		{"без API-ключа", "GET", "/api/v1/expressions", protected,
+			jsonRequest("GET", "/api/v1/expressions", "")},
+		{"завершённое выражение", "GET", "/api/v1/expressions/{id}", http.HandlerFunc(orchestrator.HandleGetExpression),
+			withID("GET", "/api/v1/expressions/"+completedID, completedID)},
+		{"выполняющееся выражение", "GET", "/api/v1/expressions/{id}", http.HandlerFunc(orchestrator.HandleGetExpression),
+			withID("GET", "/api/v1/expressions/"+processingID, processingID)},
+		{"выражение не найдено", "GET", "/api/v1/expressions/{id}", http.HandlerFunc(orchestrator.HandleGetExpression),
+			withID("GET", "/api/v1/expressions/missing", "missing")},
+		{"отмена выражения", "POST", "/api/v1/expressions/{id}/cancel", http.HandlerFunc(orchestrator.HandleCancelExpression),
+			withID("POST", "/api/v1/expressions/"+cancelID+"/cancel", cancelID)},
+		{"отмена завершённого выражения", "POST", "/api/v1/expressions/{id}/cancel", http.HandlerFunc(orchestrator.HandleCancelExpression),
+			withID("POST", "/api/v1/expressions/"+completedID+"/cancel", completedID)},
+		{"отмена неизвестного выражения", "POST", "/api/v1/expressions/{id}/cancel", http.HandlerFunc(orchestrator.HandleCancelExpression),
+			withID("POST", "/api/v1/expressions/missing/cancel", "missing")},
+		{"список агентов", "GET", "/api/v1/agents", http.HandlerFunc(orchestrator.HandleGetAgents),
+			jsonRequest("GET", "/api/v1/agents", "")},
+		{"выдача задачи", "GET", "/internal/task", http.HandlerFunc(orchestrator.HandleGetTask),
+			jsonRequest("GET", "/internal/task", "")},
+		{"неподдерживаемая версия протокола", "GET", "/internal/task", http.HandlerFunc(orchestrator.HandleGetTask),
+			func() *http.Request {
+				req := httptest.NewRequest("GET", "/internal/task", nil)
+				req.Header.Set(types.ProtocolVersionHeader, "0")
+				return req
+			}},
+		{"без секрета агента", "GET", "/internal/task", secret,
+			jsonRequest("GET", "/internal/task", "")},
+		{"результат неизвестной задачи", "POST", "/internal/task", http.HandlerFunc(orchestrator.HandleSubmitTaskResult),
+			jsonRequest("POST", "/internal/task", `{"id": "missing", "result": 1}`)},
+		{"некорректный результат", "POST", "/internal/task", http.HandlerFunc(orchestrator.HandleSubmitTaskResult),
+			jsonRequest("POST", "/internal/task", `{`)},
+		{"пакетная выдача задач", "GET", "/internal/tasks", http.HandlerFunc(orchestrator.HandleGetTasks),
+			agentRequest("GET", "/internal/tasks?max=10", "")},
+		{"пакет без max", "GET", "/internal/tasks", http.HandlerFunc(orchestrator.HandleGetTasks),
+			agentRequest("GET", "/internal/tasks", "")},
+		{"пакетная выдача по старой версии протокола", "GET", "/internal/tasks", http.HandlerFunc(orchestrator.HandleGetTasks),
+			jsonRequest("GET", "/internal/tasks?max=10", "")},
+		{"пакет результатов", "POST", "/internal/tasks", http.HandlerFunc(orchestrator.HandleSubmitTaskResults),
+			agentRequest("POST", "/internal/tasks", `{"results": [{"id": "missing", "result": 1}]}`)},
+		{"пустой пакет результатов", "POST", "/internal/tasks", http.HandlerFunc(orchestrator.HandleSubmitTaskResults),
+			agentRequest("POST", "/internal/tasks", `{"results": []}`)},
+		{"время операций", "GET", "/admin/operation-times", http.HandlerFunc(orchestrator.HandleGetOperationTimes),
+			jsonRequest("GET", "/admin/operation-times", "")},
+		{"без токена администратора", "GET", "/admin/operation-times", admin,
+			jsonRequest("GET", "/admin/operation-times", "")},
+		{"изменение времени операций", "PATCH", "/admin/operation-times", http.HandlerFunc(orchestrator.HandleUpdateOperationTimes),
+			jsonRequest("PATCH", "/admin/operation-times", `{"division_ms": 1500}`)},
+		{"отрицательное время операции", "PATCH", "/admin/operation-times", http.HandlerFunc(orchestrator.HandleUpdateOperationTimes),
+			jsonRequest("PATCH", "/admin/operation-times", `{"addition_ms": -1}`)},
+		{"время операции больше суток", "PATCH", "/admin/operation-times", http.HandlerFunc(orchestrator.HandleUpdateOperationTimes),
+			jsonRequest("PATCH", "/admin/operation-times", `{"multiplication_ms": 86400001}`)},
+		{"некорректное тело", "PATCH", "/admin/operation-times", http.HandlerFunc(orchestrator.HandleUpdateOperationTimes),
+			jsonRequest("PATCH", "/admin/operation-times", `{`)},
+	})
+
+	completeAllTasks()
+	w = httptest.NewRecorder()
+	orchestrator.HandleGetTask(w, httptest.NewRequest(http.MethodGet, "/internal/task", nil))
+	doc, _ := openapi.Parse(openapi.Orchestrator)
+	if err := doc.ValidateResponse("GET", "/internal/task", w.Code, w.Header(), w.Body.Bytes()); err != nil {
+		t.Errorf("Пустая очередь не соответствует спецификации: %v", err)
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	w := httptest.NewRecorder()
+	openapi.Handler(openapi.Orchestrator)(w, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+
+	var doc map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Невозможно распарсить спецификацию: %v", err)
+	}
+	if doc["openapi"] != "3.0.3" || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Ожидается документ OpenAPI 3.0.3 в формате JSON")
+	}
+}
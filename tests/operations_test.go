@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"calculator-service/internal/api"
+	"calculator-service/internal/auth"
+	"calculator-service/internal/orchestrator"
+	"calculator-service/internal/types"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func updateOperationTimes(body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	orchestrator.HandleUpdateOperationTimes(w, httptest.NewRequest(http.MethodPatch, "/admin/operation-times", strings.NewReader(body)))
+	return w
+}
+
+func TestOperationTimes(t *testing.T) {
+	setupTest()
+	defer orchestrator.SetOperationTimes(orchestrator.DefaultOperationTimes())
+
+	w := updateOperationTimes(`{"multiplication_ms": 250, "division_ms": 0}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+	}
+
+	w = httptest.NewRecorder()
+	orchestrator.HandleGetOperationTimes(w, httptest.NewRequest(http.MethodGet, "/admin/operation-times", nil))
+	var got types.OperationTimes
+	json.Unmarshal(w.Body.Bytes(), &got)
+	want := types.OperationTimes{AdditionMs: 1000, SubtractionMs: 1000, MultiplicationMs: 250, DivisionMs: 0}
+	if got != want {
+		t.Errorf("Время операций = %+v, ожидается %+v", got, want)
+	}
+
+	// Время берётся в момент выдачи задачи, поэтому изменение действует и на задачи в очереди
+	submitExpression(t, "6*7")
+	updateOperationTimes(`{"multiplication_ms": 40}`)
+
+	w = httptest.NewRecorder()
+	orchestrator.HandleGetTask(w, httptest.NewRequest(http.MethodGet, "/internal/task", nil))
+	var task types.Task
+	json.Unmarshal(w.Body.Bytes(), &task)
+	if task.Operation != "*" || task.OperationTime != 40 {
+		t.Errorf("Задача = %+v, ожидается operation_time 40", task)
+	}
+}
+
+func TestOperationTimesValidation(t *testing.T) {
+	defer orchestrator.SetOperationTimes(orchestrator.DefaultOperationTimes())
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"отрицательное время", `{"addition_ms": -5}`, http.StatusUnprocessableEntity, api.CodeInvalidParameter},
+		{"больше суток", `{"division_ms": 86400001}`, http.StatusUnprocessableEntity, api.CodeInvalidParameter},
+		{"переполнение", `{"multiplication_ms": 9223372036854775807}`, http.StatusUnprocessableEntity, api.CodeInvalidParameter},
+		{"не число", `{"addition_ms": "fast"}`, http.StatusBadRequest, api.CodeInvalidBody},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := updateOperationTimes(tt.body)
+			var problem api.ErrorResponse
+			json.Unmarshal(w.Body.Bytes(), &problem)
+			if w.Code != tt.wantStatus || problem.Code != tt.wantCode {
+				t.Errorf("ответ = %v %q, ожидается %v %q", w.Code, problem.Code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+
+	if got := updateOperationTimes(`{}`); !strings.Contains(got.Body.String(), `"addition_ms":1000`) {
+		t.Errorf("Неудачные изменения не должны применяться: %s", got.Body.String())
+	}
+}
+
+func TestRequireAdminToken(t *testing.T) {
+	handler := auth.RequireAdminToken("admin-token")(http.HandlerFunc(orchestrator.HandleGetOperationTimes))
+
+	for token, want := range map[string]int{"": http.StatusUnauthorized, "wrong": http.StatusUnauthorized, "admin-token": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/operation-times", nil)
+		req.Header.Set(auth.AdminTokenHeader, token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("токен %q: код статуса = %v, ожидается %v", token, w.Code, want)
+		}
+	}
+}
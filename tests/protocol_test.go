@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"bytes"
+	"calculator-service/internal/api"
+	"calculator-service/internal/orchestrator"
+	"calculator-service/internal/types"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func submitTaskResult(version string, result types.TaskResult) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(result)
+	req := httptest.NewRequest(http.MethodPost, "/internal/task", bytes.NewReader(body))
+	if version != "" {
+		req.Header.Set(types.ProtocolVersionHeader, version)
+	}
+	w := httptest.NewRecorder()
+	orchestrator.HandleSubmitTaskResult(w, req)
+	return w
+}
+
+func TestProtocolNegotiation(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		wantStatus  int
+		wantVersion string
+	}{
+		{"агент без заголовка", "", http.StatusNoContent, "1"},
+		{"агент версии 1", "1", http.StatusNoContent, "1"},
+		{"агент версии 2", "2", http.StatusNoContent, "2"},
+		{"агент версии 3", "3", http.StatusNoContent, "3"},
+		{"агент текущей версии", "4", http.StatusNoContent, "4"},
+		{"агент новее оркестратора", "7", http.StatusNoContent, "4"},
+		{"некорректная версия", "abc", http.StatusBadRequest, ""},
+		{"нулевая версия", "0", http.StatusBadRequest, ""},
+	}
+
+	setupTest()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/internal/task", nil)
+			if tt.header != "" {
+				req.Header.Set(types.ProtocolVersionHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			orchestrator.HandleGetTask(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("код статуса = %v, ожидается %v", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get(types.ProtocolVersionHeader); got != tt.wantVersion {
+				t.Errorf("%s = %q, ожидается %q", types.ProtocolVersionHeader, got, tt.wantVersion)
+			}
+			if tt.wantStatus == http.StatusBadRequest {
+				var problem api.ErrorResponse
+				json.Unmarshal(w.Body.Bytes(), &problem)
+				if problem.Code != api.CodeUnsupportedProtocol {
+					t.Errorf("код ошибки = %q, ожидается %q", problem.Code, api.CodeUnsupportedProtocol)
+				}
+			}
+		})
+	}
+}
+
+func TestTaskErrorProtocolV2(t *testing.T) {
+	setupTest()
+
+	leaderID := submitExpression(t, "6/3")
+	sharedID := submitExpression(t, "6/3+1")
+
+	req := httptest.NewRequest(http.MethodGet, "/internal/task", nil)
+	req.Header.Set(types.ProtocolVersionHeader, "2")
+	w := httptest.NewRecorder()
+	orchestrator.HandleGetTask(w, req)
+	var task types.Task
+	json.Unmarshal(w.Body.Bytes(), &task)
+	if task.Operation != "/" {
+		t.Fatalf("Первой должна выполняться общая задача деления, получена %+v", task)
+	}
+
+	if w := submitTaskResult("2", types.TaskResult{ID: task.ID, Error: "division by zero"}); w.Code != http.StatusOK {
+		t.Fatalf("код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+	}
+
+	for _, id := range []string{leaderID, sharedID} {
+		expr := getExpression(t, id)
+		if expr.Status != types.StatusError || expr.Error != "division by zero" {
+			t.Errorf("Выражение %s: статус = %v, ошибка = %q, ожидается ERROR с ошибкой агента", expr.Original, expr.Status, expr.Error)
+		}
+	}
+	if n := completeAllTasks(); n != 0 {
+		t.Errorf("После ошибки в очереди осталось %d задач, ожидается 0", n)
+	}
+}
+
+func TestTaskErrorRequiresProtocolV2(t *testing.T) {
+	setupTest()
+
+	id := submitExpression(t, "1+2")
+	w := httptest.NewRecorder()
+	orchestrator.HandleGetTask(w, httptest.NewRequest(http.MethodGet, "/internal/task", nil))
+	var task types.Task
+	json.Unmarshal(w.Body.Bytes(), &task)
+
+	w = submitTaskResult("", types.TaskResult{ID: task.ID, Error: "unsupported operation"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("код статуса = %v, ожидается %v", w.Code, http.StatusBadRequest)
+	}
+	if expr := getExpression(t, id); expr.Status != types.StatusProcessing {
+		t.Errorf("статус = %v, ожидается %v", expr.Status, types.StatusProcessing)
+	}
+
+	if w := submitTaskResult("1", types.TaskResult{ID: task.ID, Result: 3}); w.Code != http.StatusOK {
+		t.Fatalf("код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+	}
+	if expr := getExpression(t, id); expr.Status != types.StatusCompleted || expr.Result != 3 {
+		t.Errorf("статус = %v, результат = %v, ожидается COMPLETED и 3", expr.Status, expr.Result)
+	}
+}
+
+// По X-Queue-Depth агенты подбирают число воркеров: считаются только задачи с известными аргументами
+func TestQueueDepthHeader(t *testing.T) {
+	setupTest()
+
+	submitExpression(t, "1+2")
+	submitExpression(t, "3*4")
+	submitExpression(t, "(5+6)*7")
+
+	for _, want := range []string{"2", "1", "0"} {
+		w := httptest.NewRecorder()
+		orchestrator.HandleGetTask(w, httptest.NewRequest(http.MethodGet, "/internal/task", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+		}
+		if got := w.Header().Get(types.QueueDepthHeader); got != want {
+			t.Errorf("%s = %q, ожидается %q", types.QueueDepthHeader, got, want)
+		}
+	}
+
+	// Умножение ждёт результата 5+6 и в очередь готовых задач не входит
+	w := httptest.NewRecorder()
+	orchestrator.HandleGetTask(w, httptest.NewRequest(http.MethodGet, "/internal/task", nil))
+	if w.Code != http.StatusNoContent || w.Header().Get(types.QueueDepthHeader) != "0" {
+		t.Errorf("код статуса = %v, %s = %q, ожидается %v и \"0\"",
+			w.Code, types.QueueDepthHeader, w.Header().Get(types.QueueDepthHeader), http.StatusNoContent)
+	}
+}
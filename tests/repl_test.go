@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"calculator-service/internal/repl"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestREPLSession(t *testing.T) {
+	s := repl.NewSession()
+
+	steps := []struct {
+		input   string
+		want    string
+		wantErr string
+	}{
+		{input: "x = 3*4", want: "x = 12"},
+		{input: "x + 1", want: "13"},
+		{input: "ans*2", want: "26"},
+		{input: "y = 2-5", want: "y = -3"},
+		{input: "y*x", want: "-36"},
+		{input: "z+1", wantErr: "unknown variable: z"},
+		{input: "2x = 1", wantErr: "invalid variable name"},
+		{input: ":rpn (x+1)*2", want: "12 1 + 2 *"},
+		{input: ":unknown", wantErr: "unknown command"},
+		{input: ":vars", want: "ans = -36\nx = 12\ny = -3"},
+	}
+
+	for _, step := range steps {
+		got, err := s.Execute(step.input)
+		if step.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), step.wantErr) {
+				t.Errorf("Execute(%q) error = %v, ожидается %q", step.input, err, step.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != step.want {
+			t.Errorf("Execute(%q) = %q, %v, ожидается %q", step.input, got, err, step.want)
+		}
+	}
+
+	if got := len(s.History()); got != len(steps) {
+		t.Errorf("История содержит %d строк, ожидается %d", got, len(steps))
+	}
+	if _, err := s.Execute(":quit"); !errors.Is(err, repl.ErrQuit) {
+		t.Errorf("Execute(:quit) error = %v, ожидается ErrQuit", err)
+	}
+}
+
+// :ast показывает те же задачи, что создаст оркестратор: одинаковые подвыражения вычисляются один раз
+func TestREPLTree(t *testing.T) {
+	s := repl.NewSession()
+
+	got, err := s.Execute(":ast (2+3)*(3+2)-1")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	want := strings.Join([]string{
+		"-    task 3, stage 3",
+		"├── *    task 2, stage 2",
+		"│   ├── +    task 1, stage 1",
+		"│   │   ├── 2",
+		"│   │   └── 3",
+		"│   └── +    reuses task 1",
+		"└── 1",
+		"tasks: 3, stages: 3",
+	}, "\n")
+	if got != want {
+		t.Errorf("Дерево:\n%s\nожидается:\n%s", got, want)
+	}
+
+	s.Execute("4*5")
+	if got, _ := s.Execute(":rpn"); got != "4 5 *" {
+		t.Errorf(":rpn без аргумента = %q, ожидается последнее выражение", got)
+	}
+}
+
+func TestREPLComplete(t *testing.T) {
+	s := repl.NewSession()
+	s.Execute("alpha = 1")
+	s.Execute("alps = 2")
+
+	tests := []struct {
+		word string
+		want []string
+	}{
+		{"al", []string{"alpha", "alps"}},
+		{"alph", []string{"alpha"}},
+		{":h", []string{":help", ":history"}},
+		{"zz", nil},
+	}
+	for _, tt := range tests {
+		if got := s.Complete(tt.word); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Complete(%q) = %v, ожидается %v", tt.word, got, tt.want)
+		}
+	}
+}
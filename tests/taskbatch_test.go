@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"bytes"
+	"calculator-service/internal/api"
+	"calculator-service/internal/orchestrator"
+	"calculator-service/internal/types"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func getTasks(t *testing.T, query string) (*httptest.ResponseRecorder, []types.Task) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/internal/tasks"+query, nil)
+	req.Header.Set(types.ProtocolVersionHeader, strconv.Itoa(types.ProtocolV4))
+	w := httptest.NewRecorder()
+	orchestrator.HandleGetTasks(w, req)
+
+	var batch types.TaskBatch
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &batch); err != nil {
+			t.Fatalf("Невозможно распарсить пакет задач: %v", err)
+		}
+	}
+	return w, batch.Tasks
+}
+
+func submitTaskResults(t *testing.T, results ...types.TaskResult) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body, _ := json.Marshal(types.TaskResultBatch{Results: results})
+	req := httptest.NewRequest(http.MethodPost, "/internal/tasks", bytes.NewReader(body))
+	req.Header.Set(types.ProtocolVersionHeader, strconv.Itoa(types.ProtocolV4))
+	w := httptest.NewRecorder()
+	orchestrator.HandleSubmitTaskResults(w, req)
+	return w
+}
+
+func TestGetTasksBatch(t *testing.T) {
+	setupTest()
+	submitExpression(t, "1+2")
+	submitExpression(t, "3*4")
+	submitExpression(t, "5-1")
+
+	w, tasks := getTasks(t, "?max=2")
+	if w.Code != http.StatusOK || len(tasks) != 2 {
+		t.Fatalf("код статуса = %v, задач = %d, ожидается %v и 2 задачи", w.Code, len(tasks), http.StatusOK)
+	}
+	if tasks[0].Operation != "*" {
+		t.Errorf("первая операция = %q, ожидается * как задача с большим приоритетом", tasks[0].Operation)
+	}
+	for _, task := range tasks {
+		if task.OperationTime != 1000 || task.Traceparent == "" {
+			t.Errorf("задача %+v, ожидается operation_time 1000 и свой traceparent", task)
+		}
+	}
+	if depth := w.Header().Get(types.QueueDepthHeader); depth != "1" {
+		t.Errorf("%s = %q, ожидается 1", types.QueueDepthHeader, depth)
+	}
+
+	if w, tasks = getTasks(t, "?max=10"); len(tasks) != 1 {
+		t.Fatalf("задач = %d, ожидается оставшаяся 1", len(tasks))
+	}
+	if w, _ = getTasks(t, "?max=10"); w.Code != http.StatusNoContent {
+		t.Errorf("код статуса = %v, ожидается %v", w.Code, http.StatusNoContent)
+	}
+}
+
+func TestGetTasksBatchErrors(t *testing.T) {
+	setupTest()
+
+	for _, query := range []string{"", "?max=0", "?max=abc", "?max=101"} {
+		if w, _ := getTasks(t, query); w.Code != http.StatusBadRequest {
+			t.Errorf("GET /internal/tasks%s: код статуса = %v, ожидается %v", query, w.Code, http.StatusBadRequest)
+		}
+	}
+
+	// Агент, не знающий пакетных запросов, получает ошибку версии протокола
+	req := httptest.NewRequest(http.MethodGet, "/internal/tasks?max=5", nil)
+	req.Header.Set(types.ProtocolVersionHeader, "3")
+	w := httptest.NewRecorder()
+	orchestrator.HandleGetTasks(w, req)
+
+	var problem api.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &problem)
+	if w.Code != http.StatusBadRequest || problem.Code != api.CodeUnsupportedProtocol {
+		t.Errorf("код статуса = %v, код ошибки = %q, ожидается %v и %q",
+			w.Code, problem.Code, http.StatusBadRequest, api.CodeUnsupportedProtocol)
+	}
+}
+
+func TestSubmitTaskResultsBatch(t *testing.T) {
+	setupTest()
+	sumID := submitExpression(t, "1+2")
+	divID := submitExpression(t, "6/3")
+
+	_, tasks := getTasks(t, "?max=10")
+	if len(tasks) != 2 {
+		t.Fatalf("задач = %d, ожидается 2", len(tasks))
+	}
+
+	var results []types.TaskResult
+	for _, task := range tasks {
+		result := types.TaskResult{ID: task.ID, Result: calculateResultTest(task)}
+		if task.Operation == "/" {
+			result.Error = "operation failed"
+		}
+		results = append(results, result)
+	}
+	results = append(results, types.TaskResult{ID: "missing", Result: 1})
+
+	w := submitTaskResults(t, results...)
+	if w.Code != http.StatusOK {
+		t.Fatalf("код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+	}
+	var response types.TaskResultBatchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Невозможно распарсить ответ: %v", err)
+	}
+	want := []types.TaskResultStatus{
+		{ID: results[0].ID, Accepted: true},
+		{ID: results[1].ID, Accepted: true},
+		{ID: "missing", ErrorCode: api.CodeTaskNotFound, Error: "Task not found"},
+	}
+	if !reflect.DeepEqual(response.Results, want) {
+		t.Errorf("статусы = %+v, ожидается %+v", response.Results, want)
+	}
+
+	if expr := getExpression(t, sumID); expr.Status != types.StatusCompleted || expr.Result != 3 {
+		t.Errorf("выражение 1+2: %s %v, ожидается %s 3", expr.Status, expr.Result, types.StatusCompleted)
+	}
+	if expr := getExpression(t, divID); expr.Status != types.StatusError || expr.Error != "operation failed" {
+		t.Errorf("выражение 6/3: %s %q, ожидается %s с ошибкой агента", expr.Status, expr.Error, types.StatusError)
+	}
+
+	if w := submitTaskResults(t); w.Code != http.StatusBadRequest {
+		t.Errorf("пустой пакет: код статуса = %v, ожидается %v", w.Code, http.StatusBadRequest)
+	}
+}
+
+// Задача, результат которой агент не прислал до конца аренды, возвращается в очередь
+func TestTaskLeaseExpiry(t *testing.T) {
+	setupTest()
+	defer orchestrator.SetLimits(orchestrator.DefaultLimits())
+	defer orchestrator.SetOperationTimes(orchestrator.DefaultOperationTimes())
+
+	limits := orchestrator.DefaultLimits()
+	limits.TaskLeaseTimeout = 20 * time.Millisecond
+	orchestrator.SetLimits(limits)
+	orchestrator.SetOperationTimes(orchestrator.OperationTimes{})
+
+	id := submitExpression(t, "(1+2)*3")
+	_, tasks := getTasks(t, "?max=10")
+	if len(tasks) != 1 {
+		t.Fatalf("задач = %d, ожидается 1", len(tasks))
+	}
+	if w, _ := getTasks(t, "?max=10"); w.Code != http.StatusNoContent {
+		t.Fatalf("до конца аренды: код статуса = %v, ожидается %v", w.Code, http.StatusNoContent)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	_, requeued := getTasks(t, "?max=10")
+	if len(requeued) != 1 || requeued[0].ID != tasks[0].ID {
+		t.Fatalf("после конца аренды выданы задачи %+v, ожидается снова %s", requeued, tasks[0].ID)
+	}
+
+	// Агент, который не умеет складывать, возвращает задачу в очередь, но не получает её.
+	// Запоздавший результат принимается, и задача убирается из очереди
+	time.Sleep(30 * time.Millisecond)
+	if w := requestTaskAs(t, "divider", "/"); w.Code != http.StatusNoContent {
+		t.Fatalf("код статуса = %v, ожидается %v", w.Code, http.StatusNoContent)
+	}
+	if w := submitTaskResults(t, types.TaskResult{ID: tasks[0].ID, Result: 3}); w.Code != http.StatusOK {
+		t.Fatalf("код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+	}
+	_, next := getTasks(t, "?max=10")
+	if len(next) != 1 || next[0].Operation != "*" || next[0].Arg1 != 3 {
+		t.Fatalf("выданы задачи %+v, ожидается только умножение 3*3", next)
+	}
+	submitTaskResults(t, types.TaskResult{ID: next[0].ID, Result: 9})
+
+	if expr := getExpression(t, id); expr.Status != types.StatusCompleted || expr.Result != 9 {
+		t.Errorf("выражение: %s %v, ожидается %s 9", expr.Status, expr.Result, types.StatusCompleted)
+	}
+}
@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"calculator-service/internal/orchestrator"
+	"calculator-service/internal/tracing"
+	"calculator-service/internal/types"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTraceparent(t *testing.T) {
+	sc := tracing.SpanContext{
+		TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:  "00f067aa0ba902b7",
+	}
+
+	header := sc.Traceparent()
+	if header != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("Traceparent() = %s", header)
+	}
+
+	parsed, ok := tracing.ParseTraceparent(header)
+	if !ok || parsed != sc {
+		t.Errorf("ParseTraceparent(%s) = %v, %v", header, parsed, ok)
+	}
+
+	for _, invalid := range []string{"", "00-abc-def-01", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "00-zzf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"} {
+		if _, ok := tracing.ParseTraceparent(invalid); ok {
+			t.Errorf("ParseTraceparent(%q) должен вернуть ошибку", invalid)
+		}
+	}
+}
+
+func TestExpressionTrace(t *testing.T) {
+	setupTest()
+
+	var buf bytes.Buffer
+	tracing.SetDefault(tracing.NewTracer("test", tracing.NewWriterExporter(&buf)))
+	defer tracing.SetDefault(tracing.NewTracer("", nil))
+
+	calcReq := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(`{"expression": "2*3"}`))
+	orchestrator.HandleCalculate(httptest.NewRecorder(), calcReq)
+
+	taskW := httptest.NewRecorder()
+	orchestrator.HandleGetTask(taskW, httptest.NewRequest(http.MethodGet, "/internal/task", nil))
+
+	dispatchCtx, ok := tracing.ParseTraceparent(taskW.Header().Get(tracing.TraceparentHeader))
+	if !ok {
+		t.Fatalf("Ответ с задачей не содержит корректный traceparent: %q", taskW.Header().Get(tracing.TraceparentHeader))
+	}
+
+	var task types.Task
+	if err := json.Unmarshal(taskW.Body.Bytes(), &task); err != nil {
+		t.Fatalf("Невозможно распарсить ответ: %v", err)
+	}
+
+	compute := tracing.Start("agent.compute", dispatchCtx)
+	compute.End()
+
+	resultBody, _ := json.Marshal(types.TaskResult{ID: task.ID, Result: 6})
+	resultReq := httptest.NewRequest(http.MethodPost, "/internal/task", bytes.NewReader(resultBody))
+	resultReq.Header.Set(tracing.TraceparentHeader, compute.Context().Traceparent())
+	orchestrator.HandleSubmitTaskResult(httptest.NewRecorder(), resultReq)
+
+	spans := make(map[string]*tracing.Span)
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		span := &tracing.Span{}
+		if err := json.Unmarshal(scanner.Bytes(), span); err != nil {
+			t.Fatalf("Невозможно распарсить спан %q: %v", scanner.Text(), err)
+		}
+		spans[span.Name] = span
+	}
+
+	for _, name := range []string{"expression", "task.dispatch", "agent.compute", "task.result"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Fatalf("Спан %s не экспортирован", name)
+		}
+		if span.TraceID != dispatchCtx.TraceID {
+			t.Errorf("Спан %s относится к трассе %s, ожидается %s", name, span.TraceID, dispatchCtx.TraceID)
+		}
+	}
+
+	if spans["task.dispatch"].ParentSpanID != spans["expression"].SpanID {
+		t.Errorf("task.dispatch должен быть дочерним для expression")
+	}
+	if spans["task.result"].ParentSpanID != spans["agent.compute"].SpanID {
+		t.Errorf("task.result должен быть дочерним для agent.compute")
+	}
+	if spans["expression"].Attributes["status"] != types.StatusCompleted {
+		t.Errorf("Атрибут status спана expression = %v", spans["expression"].Attributes["status"])
+	}
+}
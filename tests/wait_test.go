@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"bytes"
+	"calculator-service/internal/orchestrator"
+	"calculator-service/internal/types"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runTestAgent выполняет задачи оркестратора, пока не будет закрыт stop
+func runTestAgent(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		w := httptest.NewRecorder()
+		orchestrator.HandleGetTask(w, httptest.NewRequest(http.MethodGet, "/internal/task", nil))
+		if w.Code != http.StatusOK {
+			time.Sleep(5 * time.Millisecond)
+			continue
+		}
+
+		var task types.Task
+		json.Unmarshal(w.Body.Bytes(), &task)
+		body, _ := json.Marshal(types.TaskResult{ID: task.ID, Result: calculateResultTest(task)})
+		orchestrator.HandleSubmitTaskResult(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/internal/task", bytes.NewReader(body)))
+	}
+}
+
+func TestCalculateWait(t *testing.T) {
+	setupTest()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go runTestAgent(stop)
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleCalculate(w, httptest.NewRequest(http.MethodPost, "/api/v1/calculate?wait=5s",
+		strings.NewReader(`{"expression": "2*3"}`)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("HandleCalculate() код статуса = %v, ожидается %v", w.Code, http.StatusOK)
+	}
+
+	var expr types.Expression
+	if err := json.Unmarshal(w.Body.Bytes(), &expr); err != nil {
+		t.Fatalf("Невозможно распарсить ответ: %v", err)
+	}
+	if expr.Status != types.StatusCompleted || expr.Result != 6 {
+		t.Errorf("Ожидается завершённое выражение с результатом 6, получено %+v", expr)
+	}
+}
+
+func TestCalculateWaitTimeout(t *testing.T) {
+	setupTest()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(`{"expression": "2*3"}`))
+	req.Header.Set("Prefer", "respond-async, wait=0")
+	w := httptest.NewRecorder()
+	orchestrator.HandleCalculate(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Prefer: wait=0 должен вернуть ответ без ожидания, код статуса = %v", w.Code)
+	}
+
+	start := time.Now()
+	w = httptest.NewRecorder()
+	orchestrator.HandleCalculate(w, httptest.NewRequest(http.MethodPost, "/api/v1/calculate?wait=50ms",
+		strings.NewReader(`{"expression": "2*3"}`)))
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("HandleCalculate() код статуса = %v, ожидается %v", w.Code, http.StatusAccepted)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Errorf("HandleCalculate() вернул ответ раньше окончания ожидания")
+	}
+
+	var response map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Невозможно распарсить ответ: %v", err)
+	}
+	if w.Header().Get("Location") != "/api/v1/expressions/"+response["id"] {
+		t.Errorf("Location = %q, ожидается ссылка на выражение %s", w.Header().Get("Location"), response["id"])
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(`{"expression": "42"}`))
+	req.Header.Set("Prefer", "wait=10")
+	w = httptest.NewRecorder()
+	orchestrator.HandleCalculate(w, req)
+
+	var expr types.Expression
+	if err := json.Unmarshal(w.Body.Bytes(), &expr); err != nil {
+		t.Fatalf("Невозможно распарсить ответ: %v", err)
+	}
+	if w.Code != http.StatusOK || expr.Result != 42 {
+		t.Errorf("Выражение без задач: код статуса = %v, результат = %v", w.Code, expr.Result)
+	}
+	if w.Header().Get("Preference-Applied") != "wait=10" {
+		t.Errorf("Preference-Applied = %q, ожидается wait=10", w.Header().Get("Preference-Applied"))
+	}
+
+	w = httptest.NewRecorder()
+	orchestrator.HandleCalculate(w, httptest.NewRequest(http.MethodPost, "/api/v1/calculate?wait=soon",
+		strings.NewReader(`{"expression": "2*3"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Некорректный wait: код статуса = %v, ожидается %v", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"calculator-service/internal/api"
+	"calculator-service/internal/orchestrator"
+	"calculator-service/internal/types"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func setupWebhooks(t *testing.T) {
+	t.Helper()
+
+	cfg := orchestrator.DefaultWebhookConfig()
+	cfg.Secret = "test-webhook-secret"
+	cfg.MaxAttempts = 3
+	cfg.InitialBackoff = 10 * time.Millisecond
+	// Тестовые серверы слушают loopback, который по умолчанию запрещён
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	cfg.AllowedNetworks = append(cfg.AllowedNetworks, loopback)
+	orchestrator.SetWebhookConfig(cfg)
+	t.Cleanup(func() { orchestrator.SetWebhookConfig(orchestrator.DefaultWebhookConfig()) })
+}
+
+// waitForCallback ждёт, пока доставка результата выражения не перестанет быть pending
+func waitForCallback(t *testing.T, id string) *types.WebhookDelivery {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if expr := getExpression(t, id); expr.Callback != nil && expr.Callback.Status != "pending" {
+			return expr.Callback
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Доставка webhook для выражения %s не завершилась", id)
+	return nil
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	setupTest()
+	setupWebhooks(t)
+
+	var mu sync.Mutex
+	var bodies [][]byte
+	var signatureValid bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+		bodies = append(bodies, body)
+		signatureValid = r.Header.Get(orchestrator.WebhookSignatureHeader) ==
+			orchestrator.SignWebhook("test-webhook-secret", r.Header.Get(orchestrator.WebhookTimestampHeader), body)
+
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleCalculate(w, httptest.NewRequest(http.MethodPost, "/api/v1/calculate",
+		strings.NewReader(`{"expression": "6*7", "callback_url": "`+server.URL+`/hook"}`)))
+	var response map[string]string
+	json.Unmarshal(w.Body.Bytes(), &response)
+
+	if expr := getExpression(t, response["id"]); expr.Callback == nil || expr.Callback.Status != "pending" {
+		t.Fatalf("Ожидается ожидающая доставка до завершения выражения, получено %+v", expr.Callback)
+	}
+
+	completeAllTasks()
+	delivery := waitForCallback(t, response["id"])
+
+	if delivery.Status != "delivered" || delivery.Attempts != 2 {
+		t.Errorf("Доставка: статус = %v, попыток = %v, ожидается delivered после 2 попыток", delivery.Status, delivery.Attempts)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !signatureValid {
+		t.Errorf("Подпись webhook не совпадает с HMAC тела")
+	}
+
+	var expr types.Expression
+	if err := json.Unmarshal(bodies[len(bodies)-1], &expr); err != nil {
+		t.Fatalf("Невозможно распарсить тело webhook: %v", err)
+	}
+	if expr.ID != response["id"] || expr.Status != types.StatusCompleted || expr.Result != 42 {
+		t.Errorf("Webhook получил %+v, ожидается завершённое выражение с результатом 42", expr)
+	}
+}
+
+func TestWebhookFailure(t *testing.T) {
+	setupTest()
+	setupWebhooks(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleCalculate(w, httptest.NewRequest(http.MethodPost, "/api/v1/calculate",
+		strings.NewReader(`{"expression": "5", "callback_url": "`+server.URL+`"}`)))
+	var response map[string]string
+	json.Unmarshal(w.Body.Bytes(), &response)
+
+	delivery := waitForCallback(t, response["id"])
+	if delivery.Status != "failed" || delivery.Attempts != 3 || delivery.LastStatusCode != http.StatusInternalServerError {
+		t.Errorf("Ожидается failed после 3 попыток с кодом 500, получено %+v", delivery)
+	}
+
+	w = httptest.NewRecorder()
+	orchestrator.HandleCalculate(w, httptest.NewRequest(http.MethodPost, "/api/v1/calculate",
+		strings.NewReader(`{"expression": "5", "callback_url": "ftp://example.com"}`)))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Некорректный callback_url: код статуса = %v, ожидается %v", w.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestWebhookPrivateAddresses(t *testing.T) {
+	setupTest()
+	cfg := orchestrator.DefaultWebhookConfig()
+	cfg.Secret = "test-webhook-secret"
+	orchestrator.SetWebhookConfig(cfg)
+	t.Cleanup(func() { orchestrator.SetWebhookConfig(orchestrator.DefaultWebhookConfig()) })
+
+	for _, callbackURL := range []string{
+		"http://127.0.0.1/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://localhost:8080/admin/operation-times",
+		"http://[::1]/",
+		"http://10.0.0.5/hook",
+		"http://0.0.0.0/",
+	} {
+		w := httptest.NewRecorder()
+		orchestrator.HandleCalculate(w, httptest.NewRequest(http.MethodPost, "/api/v1/calculate",
+			strings.NewReader(`{"expression": "5", "callback_url": "`+callbackURL+`"}`)))
+
+		var problem api.ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &problem)
+		if w.Code != http.StatusUnprocessableEntity || problem.Code != api.CodeInvalidCallbackURL {
+			t.Errorf("%s: ответ = %v %q, ожидается %v %q",
+				callbackURL, w.Code, problem.Code, http.StatusUnprocessableEntity, api.CodeInvalidCallbackURL)
+		}
+	}
+}
+
+func TestWebhookDialCheck(t *testing.T) {
+	setupTest()
+	setupWebhooks(t)
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { calls.Add(1) }))
+	defer server.Close()
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleCalculate(w, httptest.NewRequest(http.MethodPost, "/api/v1/calculate",
+		strings.NewReader(`{"expression": "2+3", "callback_url": "`+server.URL+`"}`)))
+	var response map[string]string
+	json.Unmarshal(w.Body.Bytes(), &response)
+
+	// Адрес, разрешённый при приёме выражения, проверяется ещё раз при соединении
+	cfg := orchestrator.DefaultWebhookConfig()
+	cfg.Secret = "test-webhook-secret"
+	cfg.MaxAttempts = 1
+	orchestrator.SetWebhookConfig(cfg)
+
+	completeAllTasks()
+	delivery := waitForCallback(t, response["id"])
+	if delivery.Status != "failed" || !strings.Contains(delivery.LastError, "not allowed") || calls.Load() != 0 {
+		t.Errorf("доставка = %+v, запросов = %d, ожидается отказ в соединении с loopback", delivery, calls.Load())
+	}
+}